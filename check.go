@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mahyarmirrashed/jdd/internal/check"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+// checkCommand validates the structure of the tree.
+func checkCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "check",
+		Usage: "report structural problems in the tree; exits non-zero if any are found",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "fix",
+				Usage: "move misplaced folders and misfiled files to where they belong",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print violations as JSON",
+			},
+		},
+		Action: checkTree,
+	}
+}
+
+// checkTree reports, and optionally fixes, violations under root.
+func checkTree(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+
+	patterns, err := jd.ParsePatterns(cfg.Patterns)
+	if err != nil {
+		return err
+	}
+
+	opts := check.Options{Patterns: patterns, Layout: cfg.SubIDLayout()}
+	violations, err := check.Run(root, opts)
+	if err != nil {
+		return fmt.Errorf("check failed: %w", err)
+	}
+
+	if cmd.Bool("fix") {
+		if cfg.DryRun {
+			for _, v := range violations {
+				if v.Fixable() {
+					log.Infof("[dry run] Would fix %s: %s", filepath.ToSlash(v.Path), v.Message)
+				}
+			}
+		} else {
+			violations, err = check.FixAll(root, opts, func(v check.Violation, dest string, err error) {
+				if err != nil {
+					log.Warnf("Could not fix %s: %v", filepath.ToSlash(v.Path), err)
+				} else {
+					log.Infof("Moved %s -> %s", filepath.ToSlash(v.Path), filepath.ToSlash(dest))
+				}
+			})
+			if err != nil {
+				return fmt.Errorf("fix failed: %w", err)
+			}
+		}
+	}
+
+	if cmd.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if violations == nil {
+			violations = []check.Violation{}
+		}
+		if err := enc.Encode(violations); err != nil {
+			return err
+		}
+	} else {
+		for _, v := range violations {
+			fmt.Printf("%s: %s: %s\n", v.Kind, filepath.ToSlash(v.Path), v.Message)
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("%d violation(s) found", len(violations))
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -24,6 +25,9 @@ import (
 
 var iconResource = fyne.NewStaticResource("icon.png", utils.Icon)
 
+// progressRefresh is how often the initial scan progress is refreshed.
+const progressRefresh = time.Second
+
 func main() {
 	a := app.New()
 	w := a.NewWindow("Johnny Decimal Daemon")
@@ -35,6 +39,10 @@ func main() {
 	delayEntry := widget.NewEntry()
 	notificationsCheck := widget.NewCheck("Enable Notifications", nil)
 	toggleBtn := widget.NewButton("Start Daemon", nil)
+	progressLabel := widget.NewLabel("")
+	progressLabel.Wrapping = fyne.TextWrapWord
+	resumeBtn := widget.NewButton("Resume Moving Files", nil)
+	resumeBtn.Hide()
 
 	homeDir, _ := os.UserHomeDir()
 	cfgDir := homeDir
@@ -44,13 +52,14 @@ func main() {
 	cfg, err := loadConfig(cfgPath)
 	if err != nil {
 		cfg = &config.Config{
-			Root:          ".",
-			LogLevel:      "info",
-			Exclude:       []string{},
-			DryRun:        false,
-			Daemonize:     false,
-			Delay:         0,
-			Notifications: false,
+			Root:             ".",
+			LogLevel:         "info",
+			Exclude:          []string{},
+			DryRun:           false,
+			Daemonize:        false,
+			Delay:            0,
+			Notifications:    false,
+			ConfirmThreshold: daemon.DefaultConfirmThreshold,
 		}
 	}
 
@@ -65,8 +74,10 @@ func main() {
 		daemonCancel  context.CancelFunc
 		daemonMu      sync.Mutex
 		daemonRunning bool
+		daemonDone    chan struct{} // Closed once the latest run has returned
 	)
 
+	// startDaemon starts the daemon on a root that already passed its checks
 	startDaemon := func() error {
 		daemonMu.Lock()
 		defer daemonMu.Unlock()
@@ -76,6 +87,11 @@ func main() {
 		}
 
 		daemonCtx, daemonCancel = context.WithCancel(context.Background())
+		runCtx := daemonCtx
+		runCfg := *cfg
+		runCfg.Preflighted = true
+		prev, done := daemonDone, make(chan struct{})
+		daemonDone = done
 
 		fyne.CurrentApp().SendNotification(&fyne.Notification{
 			Title:   "Johnny Decimal Daemon",
@@ -83,7 +99,13 @@ func main() {
 		})
 
 		go func() {
-			err := daemon.RunDaemon(daemonCtx, cfg)
+			defer close(done)
+
+			// A stopped run may still be finishing; it holds the state database
+			if prev != nil {
+				<-prev
+			}
+			err := daemon.RunDaemon(runCtx, &runCfg)
 
 			if err != nil && err != context.Canceled {
 				log.Errorf("Daemon error: %v", err)
@@ -95,8 +117,14 @@ func main() {
 			}
 
 			daemonMu.Lock()
-			daemonRunning = false
+			current := daemonDone == done
+			if current {
+				daemonRunning = false
+			}
 			daemonMu.Unlock()
+			if !current {
+				return // Restarted meanwhile
+			}
 
 			fyne.CurrentApp().SendNotification(&fyne.Notification{
 				Title:   "Johnny Decimal Daemon",
@@ -127,28 +155,58 @@ func main() {
 		return nil
 	}
 
-	toggleDaemon := func() error {
+	// startChecked starts the daemon once root passes its checks, asking
+	// before a large first scan. The checks may walk the whole tree, so they
+	// run off the UI goroutine.
+	startChecked := func() {
+		start := func() {
+			if err := startDaemon(); err != nil {
+				dialog.ShowError(fmt.Errorf("daemon control failed: %v", err), w)
+				return
+			}
+			updateToggleButton(toggleBtn, true)
+		}
+
+		toggleBtn.Disable()
+		checkCfg := cfg
+		go func() {
+			err := daemon.Preflight(checkCfg)
+			fyne.Do(func() {
+				toggleBtn.Enable()
+
+				var confirm *daemon.ConfirmError
+				switch {
+				case errors.As(err, &confirm):
+					dialog.ShowConfirm("Large First Scan",
+						fmt.Sprintf("The first scan of %s would move about %d files. Continue?", confirm.Root, confirm.Moves),
+						func(ok bool) {
+							if ok {
+								start()
+							}
+						}, w)
+				case err != nil:
+					dialog.ShowError(err, w)
+				default:
+					start()
+				}
+			})
+		}()
+	}
+
+	toggleBtn.OnTapped = func() {
 		daemonMu.Lock()
 		running := daemonRunning
 		daemonMu.Unlock()
 
-		if running {
-			return stopDaemon()
+		if !running {
+			startChecked()
+			return
 		}
-		return startDaemon()
-	}
-
-	toggleBtn.OnTapped = func() {
-		err := toggleDaemon()
-		if err != nil {
+		if err := stopDaemon(); err != nil {
 			dialog.ShowError(fmt.Errorf("daemon control failed: %v", err), w)
 			return
 		}
-
-		daemonMu.Lock()
-		running := daemonRunning
-		daemonMu.Unlock()
-		updateToggleButton(toggleBtn, running)
+		updateToggleButton(toggleBtn, false)
 	}
 
 	saveBtn := widget.NewButton("Save Configuration", func() {
@@ -158,23 +216,20 @@ func main() {
 			return
 		}
 
-		newCfg := &config.Config{
-			Root:          strings.TrimSpace(rootEntry.Text),
-			LogLevel:      "info",
-			Exclude:       parseExcludePatterns(excludePatterns.Text),
-			DryRun:        false,
-			Daemonize:     false,
-			Delay:         parsedDelay,
-			Notifications: notificationsCheck.Checked,
-		}
+		// Start from the loaded config so settings without a GUI field are kept
+		newCfg := *cfg
+		newCfg.Root = strings.TrimSpace(rootEntry.Text)
+		newCfg.Exclude = parseExcludePatterns(excludePatterns.Text)
+		newCfg.Delay = parsedDelay
+		newCfg.Notifications = notificationsCheck.Checked
 
-		err = saveConfig(cfgPath, newCfg)
+		err = saveConfig(cfgPath, &newCfg)
 		if err != nil {
 			dialog.ShowError(fmt.Errorf("failed to save config: %v", err), w)
 			return
 		}
 
-		cfg = newCfg
+		cfg = &newCfg
 
 		daemonMu.Lock()
 		running := daemonRunning
@@ -184,10 +239,10 @@ func main() {
 				dialog.ShowError(fmt.Errorf("failed to stop daemon for restart: %v", err), w)
 				return
 			}
-			if err := startDaemon(); err != nil {
-				dialog.ShowError(fmt.Errorf("failed to restart daemon: %v", err), w)
-				return
-			}
+			// The root may have changed, so it is checked again
+			updateToggleButton(toggleBtn, false)
+			startChecked()
+			return
 		}
 
 		updateToggleButton(toggleBtn, running)
@@ -209,8 +264,54 @@ func main() {
 			saveBtn,
 			toggleBtn,
 		),
+
+		progressLabel,
+		resumeBtn,
 	)
 
+	resumeBtn.OnTapped = func() {
+		dialog.ShowConfirm("Resume Moving Files",
+			"The daemon paused because files were arriving or moving unusually fast. Continue and file the files that arrived since?",
+			func(ok bool) {
+				if !ok {
+					return
+				}
+				if err := daemon.Resume(utils.ExpandTilde(cfg.Root), false); err != nil {
+					dialog.ShowError(fmt.Errorf("failed to resume: %v", err), w)
+				}
+			}, w)
+	}
+
+	// Show how far the daemon's initial scan has got, and whether it paused
+	go func() {
+		ticker := time.NewTicker(progressRefresh)
+		defer ticker.Stop()
+		for range ticker.C {
+			daemonMu.Lock()
+			running := daemonRunning
+			root := cfg.Root
+			daemonMu.Unlock()
+
+			text := ""
+			paused := false
+			if running {
+				text = progressText(root)
+				if p, err := daemon.ReadPaused(utils.ExpandTilde(root)); err == nil && p != nil {
+					text = fmt.Sprintf("Paused: %s. %d files waiting.", p.Reason, p.Held)
+					paused = true
+				}
+			}
+			fyne.Do(func() {
+				progressLabel.SetText(text)
+				if paused {
+					resumeBtn.Show()
+				} else {
+					resumeBtn.Hide()
+				}
+			})
+		}
+	}()
+
 	scroll := container.NewScroll(form)
 	content := container.NewPadded(scroll)
 	content = container.NewPadded(content)
@@ -227,6 +328,19 @@ func main() {
 	w.ShowAndRun()
 }
 
+// progressText describes the progress of the initial scan of root, or
+// returns nothing if there is none to report.
+func progressText(root string) string {
+	p, err := daemon.CurrentProgress(utils.ExpandTilde(root))
+	if err != nil || p == nil {
+		return ""
+	}
+	if p.Done {
+		return "Initial scan complete: " + p.String()
+	}
+	return "Initial scan: " + p.String()
+}
+
 func updateToggleButton(btn *widget.Button, running bool) {
 	if running {
 		btn.SetText("Stop Daemon")
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	jdd "github.com/mahyarmirrashed/jdd/internal/daemon"
+	"github.com/urfave/cli/v3"
+)
+
+// explainCommand reports what the daemon would do with files, and why.
+func explainCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "explain",
+		Usage:     "show whether the daemon would file each file, and where, or which exclude pattern or opt-out marker keeps it away",
+		ArgsUsage: "FILE...",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the explanations as JSON",
+			},
+		},
+		Action: explainFiles,
+	}
+}
+
+// explainFiles prints, for each file, what the daemon would do with it.
+func explainFiles(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+	if err := loadRules(cfg); err != nil {
+		return err
+	}
+
+	if cmd.Args().Len() == 0 {
+		return fmt.Errorf("expected at least one file")
+	}
+
+	explanations, err := jdd.Explain(cfg, cmd.Args().Slice())
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(explanations)
+	}
+
+	for _, e := range explanations {
+		switch {
+		case e.Excluded != "":
+			fmt.Printf("%s: left alone, %s\n", e.Path, e.Excluded)
+		case e.Placeholder:
+			fmt.Printf("%s: filed under a new ID\n", e.Path)
+		case e.Rule != "":
+			fmt.Printf("%s: filed in %s by rule %q\n", e.Path, e.Folder, e.Rule)
+		case e.Folder != "":
+			fmt.Printf("%s: filed in %s by the ID in its name\n", e.Path, e.Folder)
+		case cfg.Suggest:
+			fmt.Printf("%s: no ID in the filename and no rule matched; left to suggestions\n", e.Path)
+		default:
+			fmt.Printf("%s: no ID in the filename and no rule matched\n", e.Path)
+		}
+	}
+	return nil
+}
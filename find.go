@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/tree"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	"github.com/urfave/cli/v3"
+)
+
+// findResult is the JSON output of the find command.
+type findResult struct {
+	ID       string `json:"id,omitempty"`
+	SubID    string `json:"sub_id,omitempty"`
+	Area     string `json:"area"`
+	Category string `json:"category,omitempty"`
+	Path     string `json:"path"`
+}
+
+// findCommand resolves an ID to its folder.
+func findCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "find",
+		Aliases:   []string{"path"},
+		Usage:     "print the folder of an ID such as 15.23, 15.23+JEM or P01.15.23, a category or an area",
+		ArgsUsage: "ID|CATEGORY|AREA",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "open",
+				Usage: "open the folder in the file manager",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the result as JSON",
+			},
+		},
+		Action: find,
+	}
+}
+
+// find prints the absolute path of the folder for an area, category or ID,
+// without creating anything.
+func find(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("expected exactly one ID, category or area")
+	}
+
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+
+	// An ID may name its system, as in P01.15.23; otherwise it is in the primary system
+	arg := cmd.Args().First()
+	system := ""
+	if m := jd.SystemPrefixPattern.FindStringSubmatch(arg); m != nil {
+		system = m[1]
+		arg = arg[len(m[0]):]
+	}
+	root, err = cfg.SystemRoot(root, system)
+	if err != nil {
+		return err
+	}
+
+	result, err := resolve(root, arg, cfg.SubIDLayout())
+	if err != nil {
+		return err
+	}
+	path := result.Path
+
+	if cmd.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(result)
+	} else {
+		_, err = fmt.Println(path)
+	}
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("open") {
+		return utils.OpenPath(path)
+	}
+	return nil
+}
+
+// resolve looks up the folder for an area ("10-19"), category ("15") or ID
+// ("15.23" or "15.23+JEM") under root.
+func resolve(root string, arg string, layout jd.SubIDLayout) (*findResult, error) {
+	notFound := fmt.Errorf("no folder for %s under %s", arg, root)
+
+	if m := jd.AreaFolderPattern.FindStringSubmatch(arg); m != nil && m[3] == "" {
+		t, err := tree.Load(root)
+		if err != nil {
+			return nil, err
+		}
+		area := t.Area(arg)
+		if area == nil {
+			return nil, notFound
+		}
+		return &findResult{Area: area.Number, Path: area.Path}, nil
+	}
+
+	if m := jd.CategoryFolderPattern.FindStringSubmatch(arg); m != nil && m[2] == "" {
+		t, err := tree.Load(root)
+		if err != nil {
+			return nil, err
+		}
+		areaNumber := fmt.Sprintf("%c0-%c9", arg[0], arg[0])
+		category := t.Area(areaNumber).Category(arg)
+		if category == nil {
+			return nil, notFound
+		}
+		return &findResult{Area: areaNumber, Category: category.Number, Path: category.Path}, nil
+	}
+
+	jdObj, err := jd.Parse(arg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID %q: %w", arg, err)
+	}
+	jdObj.ApplyLayout(layout)
+
+	path, err := jdObj.FindFolders(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, notFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &findResult{
+		ID:       jdObj.ID,
+		SubID:    jdObj.SubID,
+		Area:     jdObj.Area,
+		Category: jdObj.Category,
+		Path:     path,
+	}, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mahyarmirrashed/jdd/internal/index"
+	"github.com/mahyarmirrashed/jdd/internal/tree"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+// indexCommand groups the commands that work with the Johnny Decimal index.
+func indexCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "index",
+		Usage: "work with the Johnny Decimal index",
+		Commands: []*cli.Command{
+			{
+				Name:  "export",
+				Usage: "print the area/category/ID hierarchy under root",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "format",
+						Aliases: []string{"f"},
+						Usage:   "output format: " + strings.Join(index.Formats, ", ") + " (default: from --output extension, else markdown)",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "write to this file instead of stdout",
+					},
+				},
+				Action: indexExport,
+			},
+			{
+				Name:      "import",
+				Usage:     "create the folders declared in a yaml or json index",
+				ArgsUsage: "FILE",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "rename",
+						Usage: "rename folders whose number matches but whose name differs",
+					},
+				},
+				Action: indexImport,
+			},
+		},
+	}
+}
+
+// indexExport writes the index of the tree under root.
+func indexExport(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+	root := utils.ExpandTilde(cfg.Root)
+
+	t, err := tree.Load(root)
+	if err != nil {
+		return fmt.Errorf("failed to read tree: %w", err)
+	}
+
+	output := cmd.String("output")
+	format := cmd.String("format")
+	if format == "" {
+		format = formatFromExtension(output)
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return index.Write(w, t, format)
+}
+
+// indexImport scaffolds the tree under root from an index file.
+func indexImport(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+	root := utils.ExpandTilde(cfg.Root)
+
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("expected exactly one index file")
+	}
+	path := cmd.Args().First()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	want, err := index.Read(f, formatFromExtension(path))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	changes, err := index.Import(root, want, index.ImportOptions{
+		DryRun: cfg.DryRun,
+		Rename: cmd.Bool("rename"),
+	})
+
+	created, renamed := "Created", "Renamed"
+	if cfg.DryRun {
+		created, renamed = "[dry run] Would create", "[dry run] Would rename"
+	}
+	applied := 0
+	for _, c := range changes {
+		switch c.Action {
+		case index.ActionCreate:
+			log.Infof("%s %s", created, filepath.ToSlash(c.To))
+			applied++
+		case index.ActionRename:
+			log.Infof("%s %s -> %s", renamed, filepath.ToSlash(c.From), filepath.ToSlash(c.To))
+			applied++
+		case index.ActionMismatch:
+			log.Warnf("Name differs: %s (index has %s); use --rename to rename it", filepath.ToSlash(c.From), filepath.Base(c.To))
+		case index.ActionProtected:
+			log.Warnf("Name differs: %s (index has %s); not renamed, as %s", filepath.ToSlash(c.From), filepath.Base(c.To), c.Reason)
+		case index.ActionMisplaced:
+			log.Warnf("Misplaced: %s (index has %s); not created again, run `jdd check --fix` to move it", filepath.ToSlash(c.From), filepath.ToSlash(c.To))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	log.Infof("Import complete: %d folder(s) changed", applied)
+	return nil
+}
+
+// formatFromExtension guesses the index format from a filename, defaulting to markdown.
+func formatFromExtension(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".csv":
+		return "csv"
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return "markdown"
+	}
+}
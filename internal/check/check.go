@@ -0,0 +1,290 @@
+package check
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mahyarmirrashed/jdd/internal/dates"
+	"github.com/mahyarmirrashed/jdd/internal/excluder"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+)
+
+// Kinds of structural violations.
+const (
+	MisplacedID       = "misplaced-id"       // ID folder under the wrong category or area
+	MisplacedCategory = "misplaced-category" // Category folder under the wrong area
+	DuplicateID       = "duplicate-id"       // Same ID number in more than one place
+	MisfiledFile      = "misfiled-file"      // File whose prefix disagrees with its folder
+	NonJDFolder       = "non-jd-folder"      // Folder at the area or category level that is not JD-numbered
+	FullCategory      = "full-category"      // Category with more than 99 IDs
+)
+
+// maxIDs is the most IDs a category can hold.
+const maxIDs = 99
+
+// Violation is a single problem found in the tree.
+type Violation struct {
+	Kind    string `json:"kind"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Fixable reports whether Fix can repair the violation.
+func (v Violation) Fixable() bool {
+	switch v.Kind {
+	case MisplacedID, MisplacedCategory, MisfiledFile:
+		return true
+	default:
+		return false
+	}
+}
+
+// Options controls how files are recognised and where they belong.
+type Options struct {
+	Patterns []*jd.FilePattern // Filename patterns; see jd.ParsePatterns
+	Layout   jd.SubIDLayout    // How sub-ID levels map to folders
+}
+
+// Run checks the tree under root and returns every violation found, in path order.
+func Run(root string, opts Options) ([]Violation, error) {
+	c := &checker{root: root, opts: opts, ids: make(map[string][]string)}
+
+	if err := c.checkLevel(root, levelRoot); err != nil {
+		return nil, err
+	}
+	if err := c.checkFiles(); err != nil {
+		return nil, err
+	}
+	c.checkDuplicates()
+
+	sort.SliceStable(c.violations, func(i, j int) bool {
+		return c.violations[i].Path < c.violations[j].Path
+	})
+	return c.violations, nil
+}
+
+// checker accumulates violations while walking the tree.
+type checker struct {
+	root       string
+	opts       Options
+	ids        map[string][]string // ID number -> folders carrying it
+	violations []Violation
+}
+
+// report records a violation.
+func (c *checker) report(kind, path, format string, args ...any) {
+	c.violations = append(c.violations, Violation{Kind: kind, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkLevel checks the folders in dir, which sits at the given level, and
+// descends into the areas and categories among them.
+func (c *checker) checkLevel(dir string, level int) error {
+	dirs, err := subdirs(dir)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+	for _, name := range dirs {
+		path := filepath.Join(dir, name)
+		// Folders marked to be left alone are neither checked nor fixed
+		if excluder.Marked(path) {
+			continue
+		}
+
+		if kind, message := placement(level, filepath.Base(dir), name); kind != "" {
+			c.report(kind, path, "%s", message)
+		}
+
+		switch {
+		case jd.AreaFolderPattern.MatchString(name):
+			if level == levelRoot {
+				if err := c.checkLevel(path, levelArea); err != nil {
+					return err
+				}
+			}
+		case jd.CategoryFolderPattern.MatchString(name):
+			if err := c.checkLevel(path, levelCategory); err != nil {
+				return err
+			}
+		case jd.IDFolderPattern.MatchString(name):
+			c.ids[name[:5]] = append(c.ids[name[:5]], path)
+			count++
+		}
+	}
+
+	if level == levelCategory && count > maxIDs {
+		c.report(FullCategory, dir, "category %s has %d IDs, more than %d", filepath.Base(dir)[:2], count, maxIDs)
+	}
+	return nil
+}
+
+// Folder checks a single area, category or ID folder against its parent, by
+// the same rules as Run. It returns the violation and true if the folder is
+// misplaced. Folders that are not JD-numbered, or that sit below the
+// category level, are never reported.
+func Folder(root, path string) (Violation, bool) {
+	name := filepath.Base(path)
+	if !jd.CategoryFolderPattern.MatchString(name) && !jd.IDFolderPattern.MatchString(name) {
+		return Violation{}, false
+	}
+
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil {
+		return Violation{}, false
+	}
+
+	var parts []string
+	if rel != "." {
+		parts = strings.Split(filepath.ToSlash(rel), "/")
+	}
+
+	level := levelRoot
+	switch {
+	case len(parts) == 0:
+	case len(parts) == 1 && jd.AreaFolderPattern.MatchString(parts[0]):
+		level = levelArea
+	case len(parts) == 2 && jd.AreaFolderPattern.MatchString(parts[0]) && jd.CategoryFolderPattern.MatchString(parts[1]):
+		level = levelCategory
+	default:
+		return Violation{}, false
+	}
+
+	kind, message := placement(level, filepath.Base(filepath.Dir(path)), name)
+	if kind == "" || kind == NonJDFolder {
+		return Violation{}, false
+	}
+	return Violation{Kind: kind, Path: path, Message: message}, true
+}
+
+// Folder levels, from root down.
+const (
+	levelRoot     = iota // Folders directly under root, which should be areas
+	levelArea            // Folders in an area, which should be its categories
+	levelCategory        // Folders in a category, which should be its IDs
+)
+
+// placement checks a folder name found at a level, inside a folder named
+// parent. It returns the kind and message of the violation, or "" if the
+// folder is where it belongs.
+func placement(level int, parent, name string) (string, string) {
+	isArea := jd.AreaFolderPattern.MatchString(name)
+	isCategory := jd.CategoryFolderPattern.MatchString(name)
+	isID := jd.IDFolderPattern.MatchString(name)
+
+	switch level {
+	case levelRoot:
+		switch {
+		case isArea:
+			return "", ""
+		case isCategory:
+			return MisplacedCategory, fmt.Sprintf("category %s is not inside an area", name[:2])
+		case isID:
+			return MisplacedID, fmt.Sprintf("ID %s is not inside a category", name[:5])
+		default:
+			return NonJDFolder, "folder at the area level is not an area"
+		}
+	case levelArea:
+		switch {
+		case isCategory && name[:1] == parent[:1]:
+			return "", ""
+		case isCategory:
+			return MisplacedCategory, fmt.Sprintf("category %s belongs in area %s0-%s9", name[:2], name[:1], name[:1])
+		case isID:
+			return MisplacedID, fmt.Sprintf("ID %s is not inside a category", name[:5])
+		default:
+			return NonJDFolder, "folder at the category level is not a category"
+		}
+	case levelCategory:
+		switch {
+		case isID && name[:2] == parent[:2]:
+			return "", ""
+		case isID:
+			return MisplacedID, fmt.Sprintf("ID %s belongs in category %s", name[:5], name[:2])
+		case isCategory:
+			return MisplacedCategory, fmt.Sprintf("category %s is inside category %s", name[:2], parent[:2])
+		}
+	}
+	return "", ""
+}
+
+// checkFiles reports Johnny Decimal files that are not inside their own ID
+// (or deepest sub-ID) folder.
+func (c *checker) checkFiles() error {
+	return filepath.WalkDir(c.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != c.root && (strings.HasPrefix(d.Name(), ".") || excluder.Marked(path)) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		jdObj, _ := jd.Match(c.opts.Patterns, d.Name())
+		if jdObj == nil {
+			return nil
+		}
+		jdObj.ApplyLayout(c.opts.Layout)
+
+		prefixes := jdObj.FolderPrefixes()
+		folder := prefixes[len(prefixes)-1]
+		if !inFolder(c.root, path, folder) {
+			c.report(MisfiledFile, path, "file %s is not in its %s folder", jdObj.ID+jdObj.SubID, folder)
+		}
+		return nil
+	})
+}
+
+// checkDuplicates reports ID numbers found in more than one folder.
+func (c *checker) checkDuplicates() {
+	for id, paths := range c.ids {
+		if len(paths) < 2 {
+			continue
+		}
+		for _, path := range paths {
+			var others []string
+			for _, other := range paths {
+				if other != path {
+					others = append(others, filepath.ToSlash(other))
+				}
+			}
+			c.report(DuplicateID, path, "ID %s also exists at %s", id, strings.Join(others, ", "))
+		}
+	}
+}
+
+// inFolder reports whether the file at path is directly inside the folder for
+// prefix (see jd.HasFolderPrefix), or inside a date bucket of that folder.
+func inFolder(root, path, prefix string) bool {
+	dir := filepath.Dir(path)
+	if dates.BucketPattern.MatchString(filepath.Base(dir)) {
+		dir = filepath.Dir(dir)
+	}
+	parent := filepath.Base(dir)
+	return dir != root && jd.HasFolderPrefix(parent, prefix)
+}
+
+// subdirs returns the names of the non-hidden directories in dir, sorted by name.
+func subdirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,280 @@
+package check
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+)
+
+// makeTree creates the folders and files under root. Paths ending in "/" are
+// folders; files are written with their own path as content.
+func makeTree(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		path := filepath.Join(root, filepath.FromSlash(p))
+		if strings.HasSuffix(p, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkTree fails unless every path exists under root and none of gone does.
+func checkTree(t *testing.T, root string, paths, gone []string) {
+	t.Helper()
+	for _, p := range paths {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(p))); err != nil {
+			t.Errorf("%s missing", p)
+		}
+	}
+	for _, p := range gone {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(p))); err == nil {
+			t.Errorf("%s still exists", p)
+		}
+	}
+}
+
+// options returns the default check options.
+func options(t *testing.T) Options {
+	t.Helper()
+	patterns, err := jd.ParsePatterns(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Options{Patterns: patterns}
+}
+
+// violation returns the only violation of the kind found under root.
+func violation(t *testing.T, root, kind string) Violation {
+	t.Helper()
+	violations, err := Run(root, options(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []Violation
+	for _, v := range violations {
+		if v.Kind == kind {
+			found = append(found, v)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("got %+v, want one %s", violations, kind)
+	}
+	return found[0]
+}
+
+func TestRun(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root,
+		"10-19 Life/15 Travel/15.23 Japan/15.23 ticket.pdf",
+		"10-19 Life/15 Travel/16.01 Rent/",
+		"10-19 Life/25 Clients/",
+		"10-19 Life/15 Travel/15.23 Japan/15.24 visa.pdf",
+		"10-19 Life/Misc/",
+		"10-19 Life/16 Money/15.23 Japan/",
+		"10-19 Life/.hidden/",
+		"10-19 Life/15 Travel/15.24 Korea/.jdkeep",
+		"10-19 Life/15 Travel/15.24 Korea/15.99 other.pdf",
+	)
+
+	violations, err := Run(root, options(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"10-19 Life/15 Travel/15.23 Japan":                DuplicateID,
+		"10-19 Life/15 Travel/15.23 Japan/15.24 visa.pdf": MisfiledFile,
+		"10-19 Life/15 Travel/16.01 Rent":                 MisplacedID,
+		"10-19 Life/16 Money/15.23 Japan":                 MisplacedID,
+		"10-19 Life/25 Clients":                           MisplacedCategory,
+		"10-19 Life/Misc":                                 NonJDFolder,
+	}
+	got := make(map[string]string)
+	for _, v := range violations {
+		path, _ := filepath.Rel(root, v.Path)
+		// The misplaced duplicate is reported twice
+		if v.Kind == DuplicateID && strings.Contains(path, "Money") {
+			continue
+		}
+		got[filepath.ToSlash(path)] = v.Kind
+	}
+	for path, kind := range want {
+		if got[path] != kind {
+			t.Errorf("%s: got %q, want %q", path, got[path], kind)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFix(t *testing.T) {
+	tests := []struct {
+		name string
+		tree []string
+		kind string
+		want []string // Paths afterwards
+		gone []string // Paths moved away
+	}{
+		{
+			"misplaced ID",
+			[]string{"10-19 Life/15 Travel/16.01 Rent/16.01 lease.pdf"},
+			MisplacedID,
+			[]string{"10-19 Life/16/16.01 Rent/16.01 lease.pdf"},
+			[]string{"10-19 Life/15 Travel/16.01 Rent"},
+		},
+		{
+			"misplaced category",
+			[]string{"10-19 Life/25 Clients/25.01 Acme/", "20-29 Work/"},
+			MisplacedCategory,
+			[]string{"20-29 Work/25 Clients/25.01 Acme"},
+			[]string{"10-19 Life/25 Clients"},
+		},
+		{
+			"misfiled file",
+			[]string{"10-19 Life/15 Travel/15.23 Japan/15.24 visa.pdf", "10-19 Life/15 Travel/15.24 Korea/"},
+			MisfiledFile,
+			[]string{"10-19 Life/15 Travel/15.24 Korea/15.24 visa.pdf"},
+			[]string{"10-19 Life/15 Travel/15.23 Japan/15.24 visa.pdf"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			makeTree(t, root, tt.tree...)
+
+			if _, err := Fix(root, options(t), violation(t, root, tt.kind), false); err != nil {
+				t.Fatal(err)
+			}
+			checkTree(t, root, tt.want, tt.gone)
+		})
+	}
+}
+
+func TestFixExisting(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root,
+		"10-19 Life/15 Travel/16.01 Rent/16.01 lease.pdf",
+		"10-19 Life/16 Money/16.01 Rent/16.01 deposit.pdf",
+	)
+	v := violation(t, root, MisplacedID)
+
+	// Without merging nothing is moved
+	if _, err := Fix(root, options(t), v, false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("got %v, want already exists", err)
+	}
+	checkTree(t, root, []string{"10-19 Life/15 Travel/16.01 Rent/16.01 lease.pdf"}, nil)
+
+	dest, err := Fix(root, options(t), v, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dest != filepath.Join(root, "10-19 Life", "16 Money", "16.01 Rent") {
+		t.Errorf("merged into %s", dest)
+	}
+	checkTree(t, root, []string{
+		"10-19 Life/16 Money/16.01 Rent/16.01 lease.pdf",
+		"10-19 Life/16 Money/16.01 Rent/16.01 deposit.pdf",
+	}, []string{"10-19 Life/15 Travel/16.01 Rent"})
+}
+
+func TestFixMarked(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root,
+		"10-19 Life/15 Travel/16.01 Rent/16.01 lease.pdf",
+		"10-19 Life/15 Travel/16.01 Rent/Old/.jdkeep",
+	)
+
+	if _, err := Fix(root, options(t), violation(t, root, MisplacedID), false); err == nil || !strings.Contains(err.Error(), "left alone") {
+		t.Fatalf("got %v, want left alone", err)
+	}
+	checkTree(t, root, []string{"10-19 Life/15 Travel/16.01 Rent/16.01 lease.pdf"}, nil)
+}
+
+func TestMerge(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root,
+		"src/a.pdf",
+		"src/Sub/b.pdf",
+		"src/New/c.pdf",
+		"dst/Sub/d.pdf",
+	)
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+
+	if err := Merge(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, root, []string{"dst/a.pdf", "dst/Sub/b.pdf", "dst/Sub/d.pdf", "dst/New/c.pdf"}, []string{"src"})
+}
+
+// TestMergeNoOverwrite refuses a merge that would overwrite a file, even
+// deep down, before moving anything.
+func TestMergeNoOverwrite(t *testing.T) {
+	tests := []struct {
+		name     string
+		conflict string // Already in dst
+	}{
+		{"file", "dst/a.pdf"},
+		{"nested file", "dst/Sub/b.pdf"},
+		{"file over folder", "dst/Sub"},
+		{"folder over file", "dst/a.pdf/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			makeTree(t, root, "src/a.pdf", "src/Sub/b.pdf", "src/c.pdf")
+			makeTree(t, root, tt.conflict)
+			src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+
+			if err := Merge(src, dst); err == nil || !strings.Contains(err.Error(), "already exists") {
+				t.Fatalf("got %v, want already exists", err)
+			}
+			checkTree(t, root, []string{"src/a.pdf", "src/Sub/b.pdf", "src/c.pdf"}, []string{"dst/c.pdf"})
+
+			data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(strings.TrimSuffix(tt.conflict, "/"))))
+			if err == nil && string(data) != tt.conflict {
+				t.Errorf("%s overwritten", tt.conflict)
+			}
+		})
+	}
+}
+
+func TestFixAll(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root,
+		"10-19 Life/25 Clients/25.01 Acme/25.01 invoice.pdf",
+		"10-19 Life/15 Travel/25.02 Beta/",
+		"10-19 Life/15 Travel/15.23 Japan/25.01 contract.pdf",
+		"20-29 Work/",
+	)
+
+	var fixed int
+	remaining, err := FixAll(root, options(t), func(v Violation, dest string, err error) {
+		if err != nil {
+			t.Errorf("fixing %s: %v", v.Path, err)
+		}
+		fixed++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed != 3 || len(remaining) != 0 {
+		t.Fatalf("fixed %d, %+v remain", fixed, remaining)
+	}
+	checkTree(t, root, []string{
+		"20-29 Work/25 Clients/25.01 Acme/25.01 invoice.pdf",
+		"20-29 Work/25 Clients/25.01 Acme/25.01 contract.pdf",
+		"20-29 Work/25 Clients/25.02 Beta",
+	}, nil)
+}
@@ -0,0 +1,165 @@
+package check
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mahyarmirrashed/jdd/internal/excluder"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+)
+
+// fixOrder is the order in which violations are fixed. Moving a category
+// also moves its IDs, and moving an ID also moves its files, so fixing the
+// outer levels first avoids redundant moves.
+var fixOrder = []string{MisplacedCategory, MisplacedID, MisfiledFile}
+
+// FixAll repairs every fixable violation under root, one kind at a time,
+// re-checking the tree between kinds since earlier fixes move later paths.
+// It calls report for each attempted fix and returns the violations that remain.
+func FixAll(root string, opts Options, report func(v Violation, dest string, err error)) ([]Violation, error) {
+	for _, kind := range fixOrder {
+		violations, err := Run(root, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range violations {
+			if v.Kind != kind {
+				continue
+			}
+			dest, err := Fix(root, opts, v, false)
+			report(v, dest, err)
+		}
+	}
+	return Run(root, opts)
+}
+
+// Fix repairs a single fixable violation by moving the folder or file to
+// where it belongs, and returns its new path. It never overwrites anything.
+// A misfiled file is recognised and placed according to opts. If merge is
+// set, a misplaced folder whose number already exists in the destination is
+// merged into it when no file would be overwritten. Nothing carrying an
+// opt-out marker, inside a folder that does or holding one is moved.
+func Fix(root string, opts Options, v Violation, merge bool) (string, error) {
+	name := filepath.Base(v.Path)
+
+	if reason, err := excluder.SubtreeMarker(root, v.Path); err != nil {
+		return "", err
+	} else if reason != "" {
+		return "", fmt.Errorf("left alone: %s", reason)
+	}
+
+	var destDir, number string
+	switch v.Kind {
+	case MisplacedCategory:
+		jdObj, err := jd.Parse(name[:2] + ".00")
+		if err != nil {
+			return "", err
+		}
+		destDir, err = jdObj.EnsureAreaFolder(root)
+		if err != nil {
+			return "", err
+		}
+		number = jdObj.Category
+	case MisplacedID:
+		jdObj, err := jd.Parse(name)
+		if err != nil {
+			return "", err
+		}
+		destDir, err = jdObj.EnsureCategoryFolder(root)
+		if err != nil {
+			return "", err
+		}
+		number = jdObj.ID
+	case MisfiledFile:
+		jdObj, _ := jd.Match(opts.Patterns, name)
+		if jdObj == nil {
+			return "", fmt.Errorf("%s matches no filename pattern", name)
+		}
+		jdObj.ApplyLayout(opts.Layout)
+		var err error
+		destDir, err = jdObj.EnsureFolders(root)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("%s cannot be fixed automatically", v.Kind)
+	}
+
+	// A folder with the same number in the destination would make a duplicate
+	if number != "" {
+		if existing, err := jd.FindPrefixedFolder(destDir, number); err == nil {
+			if merge {
+				return existing, Merge(v.Path, existing)
+			}
+			return "", fmt.Errorf("%s already exists", existing)
+		}
+	}
+
+	dest := filepath.Join(destDir, name)
+	if _, err := os.Lstat(dest); err == nil {
+		return "", fmt.Errorf("%s already exists", dest)
+	}
+	return dest, os.Rename(v.Path, dest)
+}
+
+// Merge moves the contents of folder src into folder dst, merging
+// sub-folders with the same name, and removes src. It refuses, without moving
+// anything, if any file in src would overwrite one in dst.
+func Merge(src, dst string) error {
+	if err := mergeConflicts(src, dst); err != nil {
+		return err
+	}
+	return mergeMove(src, dst)
+}
+
+// mergeConflicts returns an error for the first entry of src that cannot be
+// merged into dst.
+func mergeConflicts(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		target := filepath.Join(dst, entry.Name())
+		info, err := os.Lstat(target)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() || !info.IsDir() {
+			return fmt.Errorf("cannot merge into %s: %s already exists", dst, target)
+		}
+		if err := mergeConflicts(filepath.Join(src, entry.Name()), target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeMove moves the entries of src into dst and removes src.
+func mergeMove(src, dst string) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		from := filepath.Join(src, entry.Name())
+		target := filepath.Join(dst, entry.Name())
+		if _, err := os.Lstat(target); err == nil && entry.IsDir() {
+			if err := mergeMove(from, target); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Rename(from, target); err != nil {
+			return err
+		}
+	}
+	return os.Remove(src)
+}
@@ -1,18 +1,85 @@
 package config
 
 import (
+	"fmt"
+	"path/filepath"
 	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/rules"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
 )
 
 // Config holds the YAML configuration for the daemon.
 type Config struct {
-	Root          string        // Root directory to watch
-	LogLevel      string        // Logging level: debug, info, warn, error
-	Exclude       []string      // Glob patterns to exclude
-	DryRun        bool          // If true, don't move files
-	Daemonize     bool          // If true, run as daemon; if false, run in foreground
-	Delay         time.Duration // Time before before processing files
-	Notifications bool          // If true, send desktop notifications
+	Root             string            `yaml:"root"`              // Root directory to watch
+	LogLevel         string            `yaml:"log_level"`         // Logging level: debug, info, warn, error
+	Exclude          []string          `yaml:"exclude"`           // Glob patterns to exclude
+	DryRun           bool              `yaml:"dry_run"`           // If true, don't move files
+	Daemonize        bool              `yaml:"daemonize"`         // If true, run as daemon; if false, run in foreground
+	Delay            time.Duration     `yaml:"delay"`             // Time before before processing files
+	Notifications    bool              `yaml:"notifications"`     // If true, send desktop notifications
+	Index            bool              `yaml:"index"`             // If true, keep the index file in sync with the tree
+	IndexFile        string            `yaml:"index_file"`        // Index file path, relative to root
+	Reserved         []string          `yaml:"reserved"`          // ID ranges never allocated automatically, e.g. "00-09"
+	FixFolders       bool              `yaml:"fix_folders"`       // If true, move misplaced ID and category folders where they belong
+	Patterns         []string          `yaml:"patterns"`          // Filename patterns: preset names or expressions with named groups
+	Systems          map[string]string `yaml:"systems"`           // Root directory of each JD system, by system code, e.g. "P01"
+	PrimarySystem    string            `yaml:"primary_system"`    // System for IDs without a system prefix; root itself if empty
+	Scheme           string            `yaml:"scheme"`            // Classification scheme: johnny-decimal, dewey or para
+	DeweyDepth       int               `yaml:"dewey_depth"`       // Number of folder levels for the Dewey scheme
+	SubIDDepth       int               `yaml:"sub_id_depth"`      // Most nested sub-ID folder levels; 0 means no limit
+	DateBuckets      map[string]string `yaml:"date_buckets"`      // Date bucket, "year" or "month", by ID or category, e.g. "15.11": "year"
+	DateSources      []string          `yaml:"date_sources"`      // Where file dates come from, in order: filename, metadata, mtime
+	Inboxes          []string          `yaml:"inboxes"`           // Extra directories whose files are filed into root, e.g. "~/Downloads"
+	Rules            []rules.Rule      `yaml:"rules"`             // Rules filing files without an ID in their name
+	Suggest          bool              `yaml:"suggest"`           // If true, file unprefixed files by learned suggestions
+	SuggestThreshold float64           `yaml:"suggest_threshold"` // Confidence, from 0 to 1, needed to file by suggestion
+	Quarantine       string            `yaml:"quarantine"`        // Holding folder for files that cannot be filed, e.g. "00.01 Unsorted"; off if empty
+	RetryAttempts    int               `yaml:"retry_attempts"`    // Most attempts to move a file that fails with a transient error
+	RetryBackoff     time.Duration     `yaml:"retry_backoff"`     // Delay before the first retry, doubled for each next one
+	FullRescan       bool              `yaml:"full_rescan"`       // If true, process every file at start-up, not only those changed since the last run
+	ScanWorkers      int               `yaml:"scan_workers"`      // Directories scanned at once at start-up
+	BrakeMoves       int               `yaml:"brake_moves"`       // Moves per minute that pause the daemon until confirmed; 0 disables
+	BrakeBurst       int               `yaml:"brake_burst"`       // New files in one directory per minute that pause the daemon; 0 disables
+	Force            bool              `yaml:"force"`             // If true, watch roots that look dangerous and skip the first-scan confirmation
+	ConfirmThreshold int               `yaml:"confirm_threshold"` // Files above which the first scan of a root must be confirmed; 0 disables
+	Preflighted      bool              `yaml:"-"`                 // Set once root passed its checks, or they were confirmed, so that the daemon does not walk it again
+	FlattenSubIDs    bool              `yaml:"flatten_sub_ids"`   // If true, file all sub-ID levels in one folder, e.g. "15.23+JEM+2024"
 }
 
 const DefaultConfigFilename = ".jd.yaml"
+
+// StateDir is the directory under root where jdd keeps its own files, such as
+// locks and logs. It is never processed by the daemon.
+const StateDir = ".jdd"
+
+// DefaultIndexFile is the index note maintained when Index is enabled.
+const DefaultIndexFile = "00.00 Index.md"
+
+// SystemRoot returns the root directory of a JD system, given the watched root.
+// An empty system means the primary system, or root itself if there is none.
+// Directories may start with "~"; relative ones are taken from root.
+func (c *Config) SystemRoot(root, system string) (string, error) {
+	if system == "" {
+		system = c.PrimarySystem
+		if system == "" {
+			return root, nil
+		}
+	}
+
+	dir, ok := c.Systems[system]
+	if !ok {
+		return "", fmt.Errorf("unknown system %s", system)
+	}
+	dir = utils.ExpandTilde(dir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return filepath.Clean(dir), nil
+}
+
+// SubIDLayout returns how sub-ID levels map to folders.
+func (c *Config) SubIDLayout() jd.SubIDLayout {
+	return jd.SubIDLayout{Depth: c.SubIDDepth, Flatten: c.FlattenSubIDs}
+}
@@ -0,0 +1,252 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// Defaults for the move storm brake.
+const (
+	DefaultBrakeMoves = 100 // Moves per minute
+	DefaultBrakeBurst = 500 // New files in one directory per minute
+)
+
+// brakeWindow is the period the brake thresholds apply to.
+const brakeWindow = time.Minute
+
+// PausedFilename marks a daemon whose brake tripped, in the state directory.
+// Removing it resumes the daemon.
+const PausedFilename = "paused.json"
+
+// resumeFilename tells a daemon resuming whether to file the files held while
+// it was paused, in the state directory.
+const resumeFilename = "resume"
+
+// heldFilename holds the number of files held while paused, in the state
+// directory. It is kept apart from the paused file, which only the user
+// removes once written, so that updating it cannot undo a resume.
+const heldFilename = "held"
+
+// resumeSkip is the content of the resume file that drops the held files.
+const resumeSkip = "skip"
+
+// Paused describes why a daemon stopped moving files.
+type Paused struct {
+	Since  time.Time `json:"since"`
+	Reason string    `json:"reason"`
+	Held   int       `json:"held"` // Files that arrived since, waiting to be filed
+}
+
+// brake pauses automatic moves when files arrive or are moved faster than a
+// person would, such as during a git checkout or an archive extraction
+// inside root, until the user confirms.
+type brake struct {
+	root     string
+	maxMoves int // 0 disables the limit
+	maxBurst int // 0 disables the limit
+
+	mu      sync.Mutex
+	moves   []time.Time            // Recent moves
+	creates map[string][]time.Time // Recent new files, by directory
+	paused  *Paused
+	held    map[string]bool
+	written int // Held count last written to the held file
+}
+
+// newBrake sets up the brake for root, clearing any pause left by an earlier run.
+func newBrake(root string, cfg *config.Config) *brake {
+	if err := os.Remove(pausedPath(root)); err == nil {
+		log.Info("Cleared the pause left by the last run")
+	}
+	os.Remove(filepath.Join(root, config.StateDir, resumeFilename))
+	os.Remove(filepath.Join(root, config.StateDir, heldFilename))
+
+	return &brake{
+		root:     root,
+		maxMoves: cfg.BrakeMoves,
+		maxBurst: cfg.BrakeBurst,
+		creates:  make(map[string][]time.Time),
+		held:     make(map[string]bool),
+	}
+}
+
+// isPaused reports whether automatic moves are paused.
+func (b *brake) isPaused() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.paused != nil
+}
+
+// noteCreate counts a new file. Too many in one directory trips the brake.
+func (b *brake) noteCreate(path string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxBurst <= 0 || b.paused != nil {
+		return
+	}
+
+	dir := filepath.Dir(path)
+	now := time.Now()
+	b.creates[dir] = append(recent(b.creates[dir], now), now)
+	for d, times := range b.creates {
+		if d != dir && len(recent(times, now)) == 0 {
+			delete(b.creates, d)
+		}
+	}
+	if len(b.creates[dir]) > b.maxBurst {
+		b.trip(fmt.Sprintf("more than %d new files in %s within a minute", b.maxBurst, filepath.ToSlash(dir)))
+	}
+}
+
+// noteMoves counts n files just moved. Too many within a minute trips the brake.
+func (b *brake) noteMoves(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxMoves <= 0 || b.paused != nil || n == 0 {
+		return
+	}
+
+	now := time.Now()
+	b.moves = recent(b.moves, now)
+	for range n {
+		b.moves = append(b.moves, now)
+	}
+	if len(b.moves) > b.maxMoves {
+		b.trip(fmt.Sprintf("more than %d files moved within a minute", b.maxMoves))
+	}
+}
+
+// hold keeps a file that arrived while paused, to be filed on resuming.
+func (b *brake) hold(path string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.held[path] = true
+}
+
+// trip pauses automatic moves and tells the user. The caller holds mu.
+func (b *brake) trip(reason string) {
+	b.paused = &Paused{Since: time.Now(), Reason: reason}
+	b.moves, b.creates, b.written = nil, make(map[string][]time.Time), 0
+	b.writePaused()
+
+	out := fmt.Sprintf("Paused moving files: %s. Run `jdd resume` to continue.", reason)
+	log.Warn(out)
+	// Always notify: files stay where they are until the user confirms
+	utils.SendNotification(true, "JDD", out)
+}
+
+// poll checks whether the user confirmed resuming, and keeps the count of held
+// files current. On resuming, it returns the held files to be filed, none if
+// the user chose to skip them.
+func (b *brake) poll() (bool, []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.paused == nil {
+		return false, nil
+	}
+
+	if _, err := os.Stat(pausedPath(b.root)); err == nil {
+		if len(b.held) != b.written {
+			b.writeHeld()
+		}
+		return false, nil
+	}
+
+	resumePath := filepath.Join(b.root, config.StateDir, resumeFilename)
+	mode, _ := os.ReadFile(resumePath)
+	os.Remove(resumePath)
+	os.Remove(filepath.Join(b.root, config.StateDir, heldFilename))
+
+	var held []string
+	if string(mode) == resumeSkip {
+		log.Infof("Resumed moving files; leaving the %d files that arrived while paused", len(b.held))
+	} else {
+		log.Infof("Resumed moving files; filing the %d files that arrived while paused", len(b.held))
+		for path := range b.held {
+			held = append(held, path)
+		}
+	}
+	b.paused, b.held = nil, make(map[string]bool)
+	return true, held
+}
+
+// writePaused writes the paused file. The caller holds mu.
+func (b *brake) writePaused() {
+	data, err := json.MarshalIndent(b.paused, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(filepath.Join(b.root, config.StateDir), 0755); err == nil {
+			err = utils.WriteFileAtomic(pausedPath(b.root), data, 0644)
+		}
+	}
+	if err != nil {
+		log.Errorf("Failed to record pause: %v", err)
+	}
+}
+
+// writeHeld writes the number of files held. The caller holds mu.
+func (b *brake) writeHeld() {
+	b.written = len(b.held)
+	path := filepath.Join(b.root, config.StateDir, heldFilename)
+	if err := utils.WriteFileAtomic(path, []byte(strconv.Itoa(b.written)), 0644); err != nil {
+		log.Errorf("Failed to record held files: %v", err)
+	}
+}
+
+// recent returns the times within the brake window before now.
+func recent(times []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(times) && now.Sub(times[i]) > brakeWindow {
+		i++
+	}
+	return times[i:]
+}
+
+// pausedPath returns the paused file for root.
+func pausedPath(root string) string {
+	return filepath.Join(root, config.StateDir, PausedFilename)
+}
+
+// ReadPaused returns why the daemon on root is paused, or nil if it is not.
+func ReadPaused(root string) (*Paused, error) {
+	data, err := os.ReadFile(pausedPath(root))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p Paused
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	if held, err := os.ReadFile(filepath.Join(root, config.StateDir, heldFilename)); err == nil {
+		p.Held, _ = strconv.Atoi(string(held))
+	}
+	return &p, nil
+}
+
+// Resume confirms that a paused daemon on root may continue moving files. The
+// files that arrived while it was paused are filed too, unless skip is set.
+func Resume(root string, skip bool) error {
+	if _, err := os.Stat(pausedPath(root)); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("the daemon is not paused")
+		}
+		return err
+	}
+	if skip {
+		if err := os.WriteFile(filepath.Join(root, config.StateDir, resumeFilename), []byte(resumeSkip), 0644); err != nil {
+			return err
+		}
+	}
+	return os.Remove(pausedPath(root))
+}
@@ -0,0 +1,40 @@
+package daemon
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/dates"
+)
+
+// validateDateBuckets checks the configured date buckets and date sources.
+func validateDateBuckets(cfg *config.Config) error {
+	for key, granularity := range cfg.DateBuckets {
+		if _, err := dates.Bucket(time.Time{}, granularity); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return dates.ValidateSources(cfg.DateSources)
+}
+
+// dateBucket returns the date bucket folder, such as "2024" or "2024-03", that
+// a file classified into segments should be filed in, or "" if none applies.
+// Buckets are configured per segment, e.g. an ID ("15.11") or a category
+// ("15"); the deepest configured segment wins.
+func dateBucket(fullPath string, segments []string, cfg *config.Config) string {
+	for i := len(segments) - 1; i >= 0; i-- {
+		granularity, ok := cfg.DateBuckets[segments[i]]
+		if !ok {
+			continue
+		}
+
+		t, ok := dates.Of(fullPath, cfg.DateSources)
+		if !ok {
+			return ""
+		}
+		bucket, _ := dates.Bucket(t, granularity)
+		return bucket
+	}
+	return ""
+}
@@ -0,0 +1,212 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/rules"
+	"github.com/mahyarmirrashed/jdd/internal/scheme"
+	"github.com/mahyarmirrashed/jdd/internal/suggest"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// suggestionsShown is how many suggestions are kept for a file queued for review.
+const suggestionsShown = 3
+
+// classifier works out where a file belongs: from the ID in its name, else
+// from the first rule that matches it, else from a suggestion the model is
+// confident about.
+type classifier struct {
+	reviewMu sync.Mutex // Serialises updates to the review queue
+	root     string
+	cfg      *config.Config
+	scheme   scheme.Scheme
+	rules    *rules.Set
+	model    *suggest.Model // Nil unless suggestions are enabled
+	inboxes  []string       // Directories whose files rules and suggestions apply to: root and any extra inboxes
+}
+
+// newClassifier sets up the scheme, rules and inboxes configured in cfg.
+func newClassifier(root string, cfg *config.Config) (*classifier, error) {
+	s, err := scheme.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	inboxes := []string{absRoot}
+	for _, inbox := range cfg.Inboxes {
+		dir, err := filepath.Abs(utils.ExpandTilde(inbox))
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("inbox %s is not a directory", inbox)
+		}
+		inboxes = append(inboxes, dir)
+	}
+
+	rs, err := rules.Compile(cfg.Rules, absRoot, inboxes[1:])
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rs.Rules() {
+		if s.Parse(r.ID) == nil {
+			return nil, fmt.Errorf("%s: %q is not an ID", r.Name, r.ID)
+		}
+	}
+
+	var model *suggest.Model
+	if s.JohnnyDecimalFolders() && cfg.Suggest {
+		log.Info("Training suggestions on the existing tree...")
+		if model, err = suggest.Train(root); err != nil {
+			return nil, err
+		}
+	}
+
+	return &classifier{root: root, cfg: cfg, scheme: s, rules: rs, model: model, inboxes: inboxes}, nil
+}
+
+// classify returns the hierarchy segments and system of the file at fullPath,
+// and the name to file it under, or nil segments if it belongs nowhere. A file
+// queued for review is reported as such, and belongs nowhere for now.
+func (c *classifier) classify(fullPath string) ([]string, string, string, bool) {
+	filename := filepath.Base(fullPath)
+
+	source := filename
+	segments := c.scheme.Parse(filename)
+	if segments == nil {
+		if r := c.rules.Match(fullPath, c.inboxes); r != nil {
+			log.Debugf("Rule %q matched %s", r.Name, fullPath)
+
+			source = r.ID
+			segments = c.scheme.Parse(r.ID)
+			if r.Rename {
+				filename = r.ID + " " + filename
+			}
+		} else if id, queued := c.suggestID(fullPath); id != "" {
+			source = id
+			segments = c.scheme.Parse(id)
+		} else {
+			return nil, "", "", queued
+		}
+	}
+
+	system := ""
+	if ss, ok := c.scheme.(scheme.SystemScheme); ok {
+		system = ss.System(source)
+	}
+	return segments, system, filename, false
+}
+
+// filing is what becomes of a file, as worked out by plan.
+type filing struct {
+	placeholder *jd.Placeholder // The file asks for a new ID under destRoot
+	segments    []string        // Where the file belongs under destRoot; nil if nowhere
+	destRoot    string          // Root of the system the file is filed in
+	name        string          // Name the file is filed under
+	bucket      string          // Date bucket below its folder, if any
+	queued      bool            // The file was queued for review instead
+	failure     string          // Why the file cannot be filed; it is quarantined
+	err         error           // The error behind failure, if any
+}
+
+// plan works out what becomes of the file at fullPath under root, without
+// moving it or creating any folder. Both filing the file and estimating what
+// a scan would move go by it.
+func (c *classifier) plan(fullPath string, root string) filing {
+	if p := c.scheme.Placeholder(filepath.Base(fullPath)); p != nil {
+		destRoot, err := systemRoot(p.System, fullPath, root, c.cfg)
+		if err != nil {
+			return filing{failure: err.Error(), err: err}
+		}
+		return filing{placeholder: p, destRoot: destRoot}
+	}
+
+	segments, system, name, queued := c.classify(fullPath)
+	if segments == nil {
+		if !queued && c.loose(fullPath) {
+			return filing{failure: "no ID in the filename and no rule matched"}
+		}
+		// Nothing to do: a file without an ID inside the tree stays where it is
+		return filing{queued: queued}
+	}
+
+	destRoot, err := systemRoot(system, fullPath, root, c.cfg)
+	if err != nil {
+		return filing{failure: err.Error(), err: err}
+	}
+	return filing{segments: segments, destRoot: destRoot, name: name, bucket: dateBucket(fullPath, segments, c.cfg)}
+}
+
+// suggestID returns the ID the model is confident a file in an inbox belongs
+// to. Files it is less sure about are queued for review instead, reported by
+// returning true.
+func (c *classifier) suggestID(fullPath string) (string, bool) {
+	if c.model == nil || !c.inInbox(fullPath) {
+		return "", false
+	}
+
+	suggestions := c.model.Suggest(fullPath, suggestionsShown)
+	if len(suggestions) == 0 {
+		return "", false
+	}
+	threshold := c.cfg.SuggestThreshold
+	if threshold == 0 {
+		threshold = suggest.DefaultThreshold
+	}
+	best := suggestions[0]
+	if best.Confidence >= threshold {
+		log.Infof("Suggested %s for %s (%.0f%% confident)", best.ID, filepath.ToSlash(fullPath), best.Confidence*100)
+		return best.ID, false
+	}
+
+	if c.cfg.DryRun {
+		log.Infof("[dry run] Would queue %s for review; best guess %s (%.0f%%)", filepath.ToSlash(fullPath), best.ID, best.Confidence*100)
+		return "", true
+	}
+
+	path, err := filepath.Abs(fullPath)
+	if err == nil {
+		c.reviewMu.Lock()
+		err = suggest.Queue(c.root, suggest.Review{Path: path, Queued: time.Now(), Suggestions: suggestions})
+		c.reviewMu.Unlock()
+	}
+	if err != nil {
+		log.Warnf("Failed to queue %s for review: %v", fullPath, err)
+		return "", false
+	}
+	log.Infof("Queued %s for review; best guess %s (%.0f%%)", filepath.ToSlash(fullPath), best.ID, best.Confidence*100)
+	return "", true
+}
+
+// learn teaches the model, if any, that the file now at path, under the
+// system root destRoot, was filed there.
+func (c *classifier) learn(destRoot, path string) {
+	if c.model != nil && !c.cfg.DryRun {
+		c.model.Learn(destRoot, path)
+	}
+}
+
+// inInbox reports whether the file at fullPath is directly in root or an inbox.
+func (c *classifier) inInbox(fullPath string) bool {
+	dir, err := filepath.Abs(filepath.Dir(fullPath))
+	if err != nil {
+		return false
+	}
+	for _, inbox := range c.inboxes {
+		if dir == inbox {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// ControlFilename is the Unix socket a running daemon answers queries on, in
+// the state directory of its root.
+const ControlFilename = "control.sock"
+
+// controlTimeout bounds a query, so that a daemon that stopped answering
+// does not hang the caller.
+const controlTimeout = 2 * time.Second
+
+// Commands the control socket answers, one per connection.
+const (
+	CommandProgress = "progress" // Live progress of the initial scan
+)
+
+// controlReply is the JSON answer to a command.
+type controlReply struct {
+	Progress *Progress `json:"progress,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// scanProgress returns the progress of the running initial scan, or of the
+// last one once it is done. It is nil until the first scan starts.
+var scanProgress atomic.Pointer[func() Progress]
+
+// controlServer answers commands on the control socket of a running daemon.
+type controlServer struct {
+	ln   net.Listener
+	path string
+}
+
+// controlPath returns the control socket for root.
+func controlPath(root string) string {
+	return filepath.Join(root, config.StateDir, ControlFilename)
+}
+
+// listenControl starts answering commands on the control socket of root. A
+// socket that no daemon answers on any more, left by one that did not stop
+// cleanly, is replaced.
+func listenControl(root string) (*controlServer, error) {
+	if err := os.MkdirAll(filepath.Join(root, config.StateDir), 0755); err != nil {
+		return nil, err
+	}
+	path := controlPath(root)
+	if conn, err := net.DialTimeout("unix", path, controlTimeout); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("another daemon is answering on %s", path)
+	}
+	os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	s := &controlServer{ln: ln, path: path}
+	go s.serve()
+	return s, nil
+}
+
+// serve answers connections until the listener is closed.
+func (s *controlServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.answer(conn)
+	}
+}
+
+// answer reads one command from conn and writes its reply.
+func (s *controlServer) answer(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+
+	var reply controlReply
+	switch cmd := strings.TrimSpace(line); cmd {
+	case CommandProgress:
+		if f := scanProgress.Load(); f != nil {
+			p := (*f)()
+			reply.Progress = &p
+		}
+	default:
+		reply.Error = fmt.Sprintf("unknown command %q", cmd)
+	}
+	if err := json.NewEncoder(conn).Encode(reply); err != nil {
+		log.Debugf("Failed to answer control command: %v", err)
+	}
+}
+
+// close stops answering and removes the socket. It is safe to call on nil.
+func (s *controlServer) close() {
+	if s == nil {
+		return
+	}
+	s.ln.Close()
+	os.Remove(s.path)
+}
+
+// QueryProgress asks the daemon running on root for the live progress of its
+// initial scan. It returns nil if the daemon has not started one, and an
+// error if no daemon answers.
+func QueryProgress(root string) (*Progress, error) {
+	conn, err := net.DialTimeout("unix", controlPath(root), controlTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(controlTimeout))
+
+	if _, err := fmt.Fprintln(conn, CommandProgress); err != nil {
+		return nil, err
+	}
+	var reply controlReply
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		return nil, err
+	}
+	if reply.Error != "" {
+		return nil, fmt.Errorf("daemon: %s", reply.Error)
+	}
+	return reply.Progress, nil
+}
+
+// CurrentProgress returns the progress of the initial scan on root: live from
+// the running daemon if one answers, else as the last daemon wrote it to the
+// state directory. It returns nil if there is none.
+func CurrentProgress(root string) (*Progress, error) {
+	if p, err := QueryProgress(root); err == nil && p != nil {
+		return p, nil
+	}
+	return ReadProgress(root)
+}
@@ -0,0 +1,85 @@
+package daemon
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlProgress(t *testing.T) {
+	root := t.TempDir()
+	defer scanProgress.Store(nil)
+	scanProgress.Store(nil)
+
+	ctl, err := listenControl(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctl.close()
+
+	if p, err := QueryProgress(root); err != nil || p != nil {
+		t.Fatalf("got %v, %v before any scan", p, err)
+	}
+
+	live := func() Progress { return Progress{Dirs: 12, Files: 340, Started: time.Now()} }
+	scanProgress.Store(&live)
+	p, err := QueryProgress(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == nil || p.Dirs != 12 || p.Files != 340 {
+		t.Fatalf("got %+v", p)
+	}
+
+	if _, err := listenControl(root); err == nil {
+		t.Fatal("a second daemon took over the control socket")
+	}
+}
+
+func TestControlUnknownCommand(t *testing.T) {
+	root := t.TempDir()
+	ctl, err := listenControl(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctl.close()
+
+	conn, err := net.Dial("unix", controlPath(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintln(conn, "explode")
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(reply, "unknown command") {
+		t.Fatalf("got %q", reply)
+	}
+}
+
+// TestCurrentProgressFallback reads the progress file once no daemon answers.
+func TestCurrentProgressFallback(t *testing.T) {
+	root := t.TempDir()
+	ctl, err := listenControl(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctl.close()
+
+	if _, err := QueryProgress(root); err == nil {
+		t.Fatal("a stopped daemon answered")
+	}
+	if p, err := CurrentProgress(root); err != nil || p != nil {
+		t.Fatalf("got %v, %v with no progress written", p, err)
+	}
+
+	writeProgress(root, Progress{Dirs: 3, Done: true})
+	if p, err := CurrentProgress(root); err != nil || p == nil || p.Dirs != 3 || !p.Done {
+		t.Fatalf("got %+v, %v, want the written progress", p, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -13,15 +14,31 @@ import (
 	"github.com/mahyarmirrashed/jdd/internal/config"
 	"github.com/mahyarmirrashed/jdd/internal/excluder"
 	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/quarantine"
+	"github.com/mahyarmirrashed/jdd/internal/renumber"
+	"github.com/mahyarmirrashed/jdd/internal/retry"
+	"github.com/mahyarmirrashed/jdd/internal/scheme"
+	"github.com/mahyarmirrashed/jdd/internal/state"
 	"github.com/mahyarmirrashed/jdd/internal/utils"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/fsnotify.v1"
 )
 
+// renumberPollInterval is how often a waiting daemon checks whether renumbering finished.
+const renumberPollInterval = 500 * time.Millisecond
+
 // RunDaemon runs the main daemon process; it blocks until stopped or context cancellation.
 func RunDaemon(ctx context.Context, cfg *config.Config) error {
 	dir := utils.ExpandTilde(cfg.Root)
 
+	// Callers that checked root already, asking about a large first scan,
+	// say so rather than have it walked again
+	if !cfg.Preflighted {
+		if err := Preflight(cfg); err != nil {
+			return err
+		}
+	}
+
 	watcher, err := rfsnotify.NewWatcher()
 	if err != nil {
 		log.Fatal(err)
@@ -33,11 +50,73 @@ func RunDaemon(ctx context.Context, cfg *config.Config) error {
 		log.Fatal(err)
 	}
 
-	ex, err := excluder.New(cfg.Exclude, cfg.Root)
+	// jdd's own state directory is never processed
+	excludes := append([]string{config.StateDir + "/**"}, cfg.Exclude...)
+	ex, err := excluder.New(excludes, dir)
 	if err != nil {
 		log.Fatalf("Failed to compile exclude patterns: %v", err)
 	}
 
+	if cfg.Daemonize {
+		// The daemon's own log and PID files, in the working directory, stay put
+		if err := excludeWorkFiles(ex, dir, "jdd.log", "jdd.pid"); err != nil {
+			log.Fatalf("Failed to exclude daemon files: %v", err)
+		}
+	}
+
+	if _, err := jd.ParseRanges(cfg.Reserved); err != nil {
+		log.Fatalf("Failed to parse reserved ID ranges: %v", err)
+	}
+
+	if err := validateSystems(cfg); err != nil {
+		log.Fatalf("Failed to set up systems: %v", err)
+	}
+
+	if err := validateDateBuckets(cfg); err != nil {
+		log.Fatalf("Failed to set up date buckets: %v", err)
+	}
+
+	c, err := newClassifier(dir, cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up classification: %v", err)
+	}
+
+	if cfg.Quarantine != "" && !cfg.DryRun {
+		if _, err := quarantine.Dir(dir, cfg.Quarantine, c.scheme); err != nil {
+			log.Fatalf("Failed to set up holding folder: %v", err)
+		}
+	}
+	for _, inbox := range c.inboxes[1:] {
+		if err := watcher.Add(inbox); err != nil {
+			log.Fatalf("Failed to watch inbox %s: %v", inbox, err)
+		}
+	}
+
+	idx, err := newIndexWriter(dir, cfg)
+	if err != nil {
+		log.Fatalf("Failed to set up index: %v", err)
+	}
+	defer idx.stop()
+	if idx != nil {
+		// The index is written by the daemon itself; never file it away
+		if err := ex.AddPath(idx.path); err != nil {
+			log.Fatalf("Failed to exclude index: %v", err)
+		}
+	}
+
+	rq, err := retry.Load(dir, cfg.RetryAttempts, cfg.RetryBackoff)
+	if err != nil {
+		log.Fatalf("Failed to load retry queue: %v", err)
+	}
+
+	// `jdd status` and the GUI ask for live progress here; without it they
+	// read what the daemon writes to the state directory
+	ctl, err := listenControl(dir)
+	if err != nil {
+		log.Warnf("Control socket unavailable: %v", err)
+	}
+	defer ctl.close()
+
 	// Signal handling for graceful shutdown
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
@@ -51,6 +130,7 @@ func RunDaemon(ctx context.Context, cfg *config.Config) error {
 				log.Warnf("Error closing watcher: %v", err)
 			}
 		}
+		ctl.close()
 		if cfg.Daemonize {
 			if err := os.Remove("jdd.pid"); err != nil && !os.IsNotExist(err) {
 				log.Warnf("Error removing PID file: %v", err)
@@ -61,34 +141,113 @@ func RunDaemon(ctx context.Context, cfg *config.Config) error {
 		os.Exit(0)
 	}()
 
+	// Folder listings under root are kept current by the watcher, so they can
+	// be cached rather than read for every file filed. Others in the same
+	// process, such as the GUI once the daemon stops, read them from disk.
+	jd.EnableFolderCache(dir)
+	defer jd.DisableFolderCache()
+
+	// The state database is only kept up to date when files are really filed
+	var db *state.DB
+	if !cfg.DryRun {
+		if db, err = openState(dir, cfg); err != nil {
+			return fmt.Errorf("failed to open state database: %w", err)
+		}
+		defer db.Close()
+	}
+
 	// Initial scan
+	waitForRenumber(dir)
 	log.Info("Starting initial scan...")
-	if err := initialScan(dir, cfg, ex); err != nil {
-		log.Fatalf("Initial scan failed: %v", err)
+	if err := initialScan(ctx, dir, cfg, ex, c, rq, db); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("initial scan failed: %w", err)
+	}
+	for _, inbox := range c.inboxes[1:] {
+		if err := scanInbox(inbox, dir, cfg, ex, c, rq); err != nil {
+			return fmt.Errorf("initial scan of inbox %s failed: %w", inbox, err)
+		}
 	}
 	log.Info("Initial scan complete.")
 
-	// Main event handler loop
+	if err := idx.update(); err != nil {
+		log.Warnf("Failed to update index: %v", err)
+	}
+
+	// Main event handler loop; the brake only watches files arriving from now on
+	br := newBrake(dir, cfg)
+	retryTicker := time.NewTicker(retryPollInterval)
+	defer retryTicker.Stop()
 	go func() {
 		for {
 			select {
+			case <-retryTicker.C:
+				waitForRenumber(dir)
+				if resumed, held := br.poll(); resumed {
+					for _, path := range held {
+						if _, err := os.Stat(path); err == nil {
+							processFile(path, dir, cfg, ex, c, rq)
+						}
+					}
+				}
+				if !br.isPaused() {
+					before := filesMoved.Load()
+					retryDue(dir, cfg, ex, c, rq)
+					br.noteMoves(int(filesMoved.Load() - before))
+				}
 			case event, ok := <-watcher.Events:
 				if !ok {
 					return
 				}
+				// Folders appearing, disappearing or being renamed change the
+				// index; files moving in and out of it do not
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					jd.FolderRemoved(event.Name)
+					idx.removed(event.Name)
+				}
 				if event.Op == fsnotify.Create {
+					info, err := os.Stat(event.Name)
+					isDir := err == nil && info.IsDir()
+					if isDir {
+						jd.FolderCreated(event.Name)
+						idx.schedule()
+					}
+					if ex.IsExcluded(event.Name) {
+						log.Debugf("Excluded: %s", event.Name)
+						continue
+					}
+					if !isDir {
+						br.noteCreate(event.Name)
+					}
+
+					// Files arriving while paused wait for the user to confirm
+					if br.isPaused() {
+						if !isDir {
+							br.hold(event.Name)
+						}
+						continue
+					}
+
 					// Delay addresses an issue with Windows File Explorer
 					if cfg.Delay > 0 {
 						time.Sleep(cfg.Delay)
 					}
 
-					processFile(event.Name, dir, cfg, ex)
+					waitForRenumber(dir)
+					before := filesMoved.Load()
+					processIncluded(event.Name, dir, cfg, c, rq)
+					br.noteMoves(int(filesMoved.Load() - before))
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
 				}
 				log.Error("error:", err)
+				// Events may have been dropped, e.g. when the inotify queue
+				// overflowed, so cached folder listings can no longer be trusted
+				jd.ResetFolderCache()
 			}
 		}
 	}()
@@ -105,16 +264,58 @@ func RunDaemon(ctx context.Context, cfg *config.Config) error {
 	return nil
 }
 
-// processFile checks if the filename matches the Johnny Decimal pattern,
-// ensures the correct folder structure, and moves the file if needed.
-// Returns true if the file was processed.
-func processFile(fullPath string, root string, cfg *config.Config, ex *excluder.Excluder) bool {
-	filename := filepath.Base(fullPath)
+// waitForRenumber blocks while `jdd renumber` is running under root, so that
+// the daemon does not move files back while their prefixes are rewritten.
+func waitForRenumber(root string) {
+	if !renumber.InProgress(root) {
+		return
+	}
+
+	log.Info("Renumbering in progress, holding off...")
+	for renumber.InProgress(root) {
+		time.Sleep(renumberPollInterval)
+	}
+	log.Info("Renumbering finished, resuming")
+}
 
+// excludeWorkFiles excludes the named files in the working directory, if it is
+// under root.
+func excludeWorkFiles(ex *excluder.Excluder, root string, names ...string) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		path, err := filepath.Abs(name)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(absRoot, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if err := ex.AddPath(filepath.Join(root, rel)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// processFile checks if the file is classified by its name or a rule,
+// ensures the correct folder structure, and moves the file if needed.
+// Returns true if the file was filed or needs nothing done, so that it need
+// not be looked at again until it changes.
+func processFile(fullPath string, root string, cfg *config.Config, ex *excluder.Excluder, c *classifier, rq *retry.Queue) bool {
 	if ex.IsExcluded(fullPath) {
 		log.Debugf("Excluded: %s", fullPath)
 		return false
 	}
+	return processIncluded(fullPath, root, cfg, c, rq)
+}
+
+// processIncluded is processFile for a file already known not to be excluded.
+func processIncluded(fullPath string, root string, cfg *config.Config, c *classifier, rq *retry.Queue) bool {
+	filename := filepath.Base(fullPath)
 
 	info, err := os.Stat(fullPath)
 	if err != nil {
@@ -122,67 +323,107 @@ func processFile(fullPath string, root string, cfg *config.Config, ex *excluder.
 		return false
 	}
 	if info.IsDir() {
+		if c.fixesFolders() && processFolder(fullPath, root, cfg) {
+			return true
+		}
 		log.Infof("Skipping directory: %s", fullPath)
 		return false
 	}
 
-	if jd.JohnnyDecimalFilePattern.MatchString(filename) {
-		jdObj, err := jd.Parse(filename)
-		if err != nil {
-			log.Warnf("Johnny Decimal parsing error: %v", err)
-			return false
+	f := c.plan(fullPath, root)
+	switch {
+	case f.failure != "":
+		if f.err != nil {
+			log.Warnf("Cannot file %s: %v", filename, f.err)
 		}
-
-		destDir, err := jdObj.EnsureFolders(root)
-		if err != nil {
-			log.Warnf("Error creating folders: %v", err)
+		fileFailed(fullPath, root, cfg, c, rq, f.failure, f.err)
+		return false
+	case f.placeholder != nil:
+		if err := processPlaceholder(fullPath, f.placeholder, f.destRoot, cfg); err != nil {
+			fileFailed(fullPath, root, cfg, c, rq, fmt.Sprintf("could not file under a new ID: %v", err), err)
 			return false
 		}
+		fileDone(fullPath, rq)
+		return true
+	case f.segments == nil:
+		// Compliant unless queued for review
+		return !f.queued
+	}
 
-		oldPath := fullPath
-		newPath := filepath.Join(destDir, filename)
-
-		prettyPath := func(path string) string { return filepath.ToSlash(path) }
-
-		if oldPath != newPath {
-			if !cfg.DryRun {
-				err = os.Rename(oldPath, newPath)
-				if err != nil {
-					out := fmt.Sprintf("Error moving %s: %v", filename, err)
-					// Log and send notification
-					log.Error(out)
-					utils.SendNotification(cfg.Notifications, "JDD", out)
-				} else {
-					out := fmt.Sprintf("Moved %s -> %s", prettyPath(oldPath), prettyPath(newPath))
-					// Log and send notification
-					log.Info(out)
-					utils.SendNotification(cfg.Notifications, "JDD", out)
-				}
-			} else {
-				out := fmt.Sprintf("[dry run] Would move %s -> %s", prettyPath(oldPath), prettyPath(newPath))
-				// Log and send notification
-				log.Info(out)
-				utils.SendNotification(cfg.Notifications, "JDD", out)
+	destDir, err := scheme.EnsureFolders(c.scheme, f.destRoot, f.segments)
+	if err != nil {
+		log.Warnf("Error creating folders: %v", err)
+		fileFailed(fullPath, root, cfg, c, rq, fmt.Sprintf("could not create folders: %v", err), err)
+		return false
+	}
+
+	if f.bucket != "" {
+		destDir = filepath.Join(destDir, f.bucket)
+		if !cfg.DryRun {
+			if err := os.MkdirAll(destDir, 0755); err != nil {
+				log.Warnf("Error creating folders: %v", err)
+				fileFailed(fullPath, root, cfg, c, rq, fmt.Sprintf("could not create folders: %v", err), err)
+				return false
 			}
 		}
-		return true
 	}
 
-	return false
-}
+	oldPath := fullPath
+	newPath := filepath.Join(destDir, f.name)
 
-// initialScan walks the entire directory and ensures Johnny Decimal adherence.
-func initialScan(root string, cfg *config.Config, ex *excluder.Excluder) error {
-	return filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
+	if oldPath != newPath {
+		if err := moveFile(oldPath, newPath, cfg); err != nil {
+			fileFailed(fullPath, root, cfg, c, rq, fmt.Sprintf("could not move to %s: %v", filepath.ToSlash(destDir), err), err)
+			return false
 		}
+		// Later suggestions take what was just filed into account
+		c.learn(f.destRoot, newPath)
+	}
+	fileDone(fullPath, rq)
+	return true
+}
 
-		if d.IsDir() {
-			return nil
-		}
+// moveFile moves a file to newPath, which may carry a different filename,
+// and logs and notifies the outcome. In dry-run mode it only reports the move.
+func moveFile(oldPath string, newPath string, cfg *config.Config) error {
+	prettyPath := func(path string) string { return filepath.ToSlash(path) }
 
-		processFile(path, root, cfg, ex)
+	if cfg.DryRun {
+		filesMoved.Add(1)
+		out := fmt.Sprintf("[dry run] Would move %s -> %s", prettyPath(oldPath), prettyPath(newPath))
+		// Log and send notification
+		log.Info(out)
+		utils.SendNotification(cfg.Notifications, "JDD", out)
 		return nil
-	})
+	}
+
+	err := utils.MoveFile(oldPath, newPath)
+	if err != nil {
+		out := fmt.Sprintf("Error moving %s: %v", filepath.Base(oldPath), err)
+		// Log and send notification
+		log.Error(out)
+		utils.SendNotification(cfg.Notifications, "JDD", out)
+		return err
+	}
+
+	filesMoved.Add(1)
+	out := fmt.Sprintf("Moved %s -> %s", prettyPath(oldPath), prettyPath(newPath))
+	// Log and send notification
+	log.Info(out)
+	utils.SendNotification(cfg.Notifications, "JDD", out)
+	return nil
+}
+
+// scanInbox files the files directly in an inbox outside root.
+func scanInbox(inbox string, root string, cfg *config.Config, ex *excluder.Excluder, c *classifier, rq *retry.Queue) error {
+	entries, err := os.ReadDir(inbox)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			processFile(filepath.Join(inbox, entry.Name()), root, cfg, ex, c, rq)
+		}
+	}
+	return nil
 }
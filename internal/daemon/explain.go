@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/excluder"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+)
+
+// Explanation says what the daemon would do with a file, and why.
+type Explanation struct {
+	Path        string `json:"path"`
+	Excluded    string `json:"excluded,omitempty"`    // Why the file is left alone: the exclude pattern or opt-out marker that applies
+	Placeholder bool   `json:"placeholder,omitempty"` // The file asks for a new ID
+	Folder      string `json:"folder,omitempty"`      // Folder prefixes, outermost first, of where the file belongs
+	Rule        string `json:"rule,omitempty"`        // The rule that placed a file without an ID in its name
+}
+
+// Explain works out what the daemon would do with each of paths, without
+// touching them. Suggestions are not consulted.
+func Explain(cfg *config.Config, paths []string) ([]Explanation, error) {
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return nil, err
+	}
+
+	ex, err := excluder.New(append([]string{config.StateDir + "/**"}, cfg.Exclude...), root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Training the model would only slow this down
+	noSuggest := *cfg
+	noSuggest.Suggest = false
+	c, err := newClassifier(root, &noSuggest)
+	if err != nil {
+		return nil, err
+	}
+
+	explanations := make([]Explanation, 0, len(paths))
+	for _, path := range paths {
+		e := Explanation{Path: path}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		filename := filepath.Base(abs)
+
+		if reason := ex.Reason(abs); reason != "" {
+			e.Excluded = reason
+		} else if c.scheme.Placeholder(filename) != nil {
+			e.Placeholder = true
+		} else if segments := c.scheme.Parse(filename); segments != nil {
+			e.Folder = strings.Join(c.scheme.Prefixes(segments), "/")
+		} else if r := c.rules.Match(abs, c.inboxes); r != nil {
+			e.Rule = r.Name
+			e.Folder = strings.Join(c.scheme.Prefixes(c.scheme.Parse(r.ID)), "/")
+		}
+		explanations = append(explanations, e)
+	}
+	return explanations, nil
+}
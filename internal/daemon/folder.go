@@ -0,0 +1,51 @@
+package daemon
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/mahyarmirrashed/jdd/internal/check"
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// fixesFolders reports whether misplaced folders are moved where they belong.
+// Folder fixes only make sense for Johnny Decimal.
+func (c *classifier) fixesFolders() bool {
+	return c.cfg.FixFolders && c.scheme.JohnnyDecimalFolders()
+}
+
+// processFolder moves an ID or category folder that was created or moved
+// into the wrong place to where it belongs, merging it into an existing
+// folder with the same number when no file would be overwritten. Returns true
+// if the folder was misplaced.
+func processFolder(fullPath string, root string, cfg *config.Config) bool {
+	v, ok := check.Folder(root, fullPath)
+	if !ok {
+		return false
+	}
+
+	if cfg.DryRun {
+		out := fmt.Sprintf("[dry run] Would fix %s: %s", filepath.ToSlash(fullPath), v.Message)
+		// Log and send notification
+		log.Info(out)
+		utils.SendNotification(cfg.Notifications, "JDD", out)
+		return true
+	}
+
+	dest, err := check.Fix(root, check.Options{}, v, true)
+	if err != nil {
+		out := fmt.Sprintf("Error moving folder %s: %v", filepath.Base(fullPath), err)
+		// Log and send notification
+		log.Error(out)
+		utils.SendNotification(cfg.Notifications, "JDD", out)
+		return true
+	}
+
+	out := fmt.Sprintf("Moved folder %s -> %s", filepath.ToSlash(fullPath), filepath.ToSlash(dest))
+	// Log and send notification
+	log.Info(out)
+	utils.SendNotification(cfg.Notifications, "JDD", out)
+	return true
+}
@@ -0,0 +1,237 @@
+package daemon
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/mahyarmirrashed/jdd/internal/check"
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/excluder"
+	"github.com/mahyarmirrashed/jdd/internal/scheme"
+	"github.com/mahyarmirrashed/jdd/internal/state"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+)
+
+// DefaultConfirmThreshold is the number of files moved above which the first
+// scan of a root needs confirming.
+const DefaultConfirmThreshold = 1000
+
+// ConfirmError asks the user to confirm that the first scan of a root would
+// move many files.
+type ConfirmError struct {
+	Root      string
+	Moves     int // Estimated files moved
+	Threshold int
+}
+
+func (e *ConfirmError) Error() string {
+	return fmt.Sprintf("the first scan of %s would move about %d files, more than %d; confirm or start with --force", e.Root, e.Moves, e.Threshold)
+}
+
+// Preflight checks that root is safe to watch before the daemon touches
+// anything. Unless cfg.Force is set, it refuses roots that are, or contain,
+// the home directory, lie in a system directory, are the mount point of a
+// system volume or hold a git worktree; and when root was never scanned
+// before and its first scan would move more files than the confirm
+// threshold, it returns a *ConfirmError. A root carrying an opt-out marker
+// is refused even with cfg.Force: nothing in it may be touched.
+func Preflight(cfg *config.Config) error {
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+
+	if reason := excluder.Marker(root, root); reason != "" {
+		return fmt.Errorf("refusing to watch %s: it is marked to be left alone (%s); remove the marker to let jdd file there", root, reason)
+	}
+	if cfg.Force {
+		return nil
+	}
+
+	if reason := dangerousRoot(root); reason != "" {
+		return fmt.Errorf("refusing to watch %s: %s; use --force to watch it anyway", root, reason)
+	}
+
+	if cfg.DryRun || cfg.ConfirmThreshold <= 0 || state.Exists(root) {
+		return nil
+	}
+	moves, err := countMoves(root, cfg)
+	if err != nil {
+		return err
+	}
+	if moves > cfg.ConfirmThreshold {
+		return &ConfirmError{Root: root, Moves: moves, Threshold: cfg.ConfirmThreshold}
+	}
+	return nil
+}
+
+// dangerousRoot returns why the absolute directory root must not be watched,
+// or nothing if it may be.
+func dangerousRoot(root string) string {
+	if filepath.Dir(root) == root {
+		return "it is the root of the filesystem"
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(home); err == nil {
+			home = resolved
+		}
+		if root == home {
+			return "it is the home directory"
+		}
+		if within(home, root) {
+			return "it contains the home directory"
+		}
+	}
+
+	trees, dirs := systemDirs()
+	for _, dir := range trees {
+		if root == dir || within(root, dir) {
+			return fmt.Sprintf("it is a system directory (%s)", dir)
+		}
+	}
+	for _, dir := range dirs {
+		if root == dir {
+			return "it is a system directory"
+		}
+	}
+	if isMountPoint(root) && holdsSystem(root) {
+		return "it is the mount point of a volume holding an operating system"
+	}
+
+	if _, err := os.Lstat(filepath.Join(root, ".git")); err == nil {
+		return "it is a git worktree"
+	}
+	return ""
+}
+
+// systemDirs returns the directories holding the operating system and
+// installed programs, no part of which may be watched, and the directories
+// that may not be watched themselves, though folders inside them may.
+func systemDirs() (trees, dirs []string) {
+	switch runtime.GOOS {
+	case "windows":
+		for _, env := range []string{"SystemRoot", "ProgramFiles", "ProgramFiles(x86)"} {
+			if dir := os.Getenv(env); dir != "" {
+				trees = append(trees, filepath.Clean(dir))
+			}
+		}
+		if dir := os.Getenv("ProgramData"); dir != "" {
+			dirs = append(dirs, filepath.Clean(dir))
+		}
+		return trees, dirs
+	case "darwin":
+		// /etc and /var are links into /private, and roots have their links resolved
+		return []string{"/System", "/Library", "/Applications", "/bin", "/sbin", "/usr", "/dev", "/private/etc"},
+			[]string{"/private", "/private/var", "/opt", "/Volumes", "/Users"}
+	default:
+		return []string{"/bin", "/boot", "/dev", "/etc", "/lib", "/lib32", "/lib64", "/proc", "/run", "/sbin", "/sys", "/usr"},
+			[]string{"/var", "/opt", "/srv", "/mnt", "/media", "/home"}
+	}
+}
+
+// systemProbes are paths found at the top of a volume holding an operating
+// system: Windows, macOS and other Unix systems in turn.
+var systemProbes = []string{"Windows/System32", "System/Library/CoreServices", "etc/fstab", "usr/bin"}
+
+// holdsSystem reports whether dir looks like the top of a volume holding an
+// operating system, such as /mnt/c under WSL or a startup disk under /Volumes.
+func holdsSystem(dir string) bool {
+	for _, probe := range systemProbes {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(probe))); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether path is strictly inside dir.
+func within(path, dir string) bool {
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// countMoves estimates how many files under root, and directly in any inbox,
+// a first scan would move, going by the same plan as filing them: files filed
+// elsewhere, placed in a date bucket or under another system's root, given a
+// new ID, or quarantined. With folder fixes on, a misplaced folder counts as
+// one move. Suggestions are not consulted.
+func countMoves(root string, cfg *config.Config) (int, error) {
+	ex, err := excluder.New(append([]string{config.StateDir + "/**"}, cfg.Exclude...), root)
+	if err != nil {
+		return 0, err
+	}
+	noSuggest := *cfg
+	noSuggest.Suggest = false
+	c, err := newClassifier(root, &noSuggest)
+	if err != nil {
+		return 0, err
+	}
+
+	moves := 0
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable folders are not scanned either
+			return nil
+		}
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			if excluder.Marked(path) {
+				return filepath.SkipDir
+			}
+			if c.fixesFolders() {
+				if _, misplaced := check.Folder(root, path); misplaced {
+					// Moved as a whole, with everything in it
+					moves++
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if !ex.Skip(path) && c.wouldMove(root, path) {
+			moves++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, inbox := range c.inboxes[1:] {
+		entries, err := os.ReadDir(inbox)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(inbox, entry.Name())
+			if !entry.IsDir() && !ex.IsExcluded(path) && c.wouldMove(root, path) {
+				moves++
+			}
+		}
+	}
+	return moves, nil
+}
+
+// wouldMove reports whether filing the file at path under root would move it.
+func (c *classifier) wouldMove(root, path string) bool {
+	f := c.plan(path, root)
+	switch {
+	case f.failure != "":
+		return quarantines(path, c.cfg)
+	case f.placeholder != nil:
+		return true
+	case f.segments == nil:
+		return false
+	}
+
+	dest := filepath.Join(scheme.FindFolders(c.scheme, f.destRoot, f.segments), f.bucket, f.name)
+	return filepath.Clean(path) != dest
+}
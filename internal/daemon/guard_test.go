@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/excluder"
+)
+
+// TestCountMoves checks that the estimate of a first scan goes by what filing
+// would do: date buckets, other systems, quarantine and folder fixes included.
+func TestCountMoves(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   config.Config
+		paths []string // Files, or folders ending in "/", under root
+		want  int
+	}{
+		{"filed", config.Config{}, []string{
+			"10-19/15/15.23/15.23 ticket.pdf",
+			"20-29 Home/21 Moving/21.05 Lease/21.05 lease.pdf",
+			"10-19/15/15.23/notes.txt",
+		}, 0},
+		{"misfiled", config.Config{}, []string{
+			"15.23 ticket.pdf",
+			"10-19/15/15.24 visa.pdf",
+			"10-19/15/15.23/Scans/15.23 scan.pdf",
+			"15.xx booking.pdf",
+		}, 4},
+		{"date bucket", config.Config{DateBuckets: map[string]string{"15.23": "year"}, DateSources: []string{"filename"}}, []string{
+			"10-19/15/15.23/2024/15.23 2024-03-15 ticket.pdf",
+			"10-19/15/15.23/15.23 2024-04-01 visa.pdf",
+			"10-19/15/15.23/15.23 undated.pdf",
+		}, 1},
+		{"systems", config.Config{Systems: map[string]string{"P01": "Personal", "W02": "Work"}, PrimarySystem: "P01"}, []string{
+			"Personal/10-19/15/15.23/15.23 ticket.pdf",
+			"Work/30-39/31/31.04/31.04 invoice.pdf",
+			"W02.31.05 contract.pdf",
+			"Personal/10-19/15/15.23/W02.31.06 receipt.pdf",
+		}, 2},
+		{"unknown system", config.Config{}, []string{"X09.15.23 ticket.pdf"}, 0},
+		{"unknown system quarantined", config.Config{Quarantine: "00.01 Unsorted"}, []string{"X09.15.23 ticket.pdf"}, 1},
+		{"quarantine", config.Config{Quarantine: "00.01 Unsorted"}, []string{
+			"notes.txt",
+			"10-19/15 Travel/packing list.txt",
+			"10-19/15/15.23/notes.txt",
+			".hidden",
+			"download.crdownload",
+		}, 2},
+		{"folder fixes", config.Config{FixFolders: true}, []string{
+			"10-19/16/15.23 Japan/15.23 visa.pdf",
+			"10-19/16/15.23 Japan/15.23 ticket.pdf",
+			"10-19/15/15.24/",
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, path := range tt.paths {
+				full := filepath.Join(root, filepath.FromSlash(path))
+				if strings.HasSuffix(path, "/") {
+					if err := os.MkdirAll(full, 0755); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(full, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg := tt.cfg
+			cfg.Root = root
+			got, err := countMoves(root, &cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d moves, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPreflightMarkedRoot refuses a root marked to be left alone, even when
+// forced.
+func TestPreflightMarkedRoot(t *testing.T) {
+	root := t.TempDir()
+	if err := Preflight(&config.Config{Root: root, Force: true}); err != nil {
+		t.Fatalf("refused an unmarked root: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, excluder.KeepFilename), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, force := range []bool{false, true} {
+		err := Preflight(&config.Config{Root: root, Force: force})
+		if err == nil || !strings.Contains(err.Error(), excluder.KeepFilename) {
+			t.Errorf("got %v with force %v, want the marker named", err, force)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/index"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/tree"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// indexDebounce is how long the index writer waits for the tree to settle
+// before regenerating the index.
+const indexDebounce = time.Second
+
+// indexWriter keeps the markdown index under root in sync with the folder tree.
+// A nil *indexWriter is valid and does nothing.
+type indexWriter struct {
+	root string
+	path string
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	folders map[string]bool // Folders listed by the last update
+
+	updateMu sync.Mutex // Held while updating, so that one update writes the file at a time
+}
+
+// newIndexWriter returns an indexWriter for the configured index file, or nil
+// if index maintenance is disabled.
+func newIndexWriter(root string, cfg *config.Config) (*indexWriter, error) {
+	if !cfg.Index {
+		return nil, nil
+	}
+	if cfg.DryRun {
+		log.Info("[dry run] Index maintenance disabled")
+		return nil, nil
+	}
+
+	path, err := resolveIndexPath(root, cfg.IndexFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &indexWriter{root: root, path: path}, nil
+}
+
+// resolveIndexPath returns the location of the index file. A bare Johnny
+// Decimal filename such as "00.00 Index.md" is placed in its ID folder, just
+// as the daemon would file it; any other relative path is relative to root.
+func resolveIndexPath(root string, file string) (string, error) {
+	if file == "" {
+		file = config.DefaultIndexFile
+	}
+	file = utils.ExpandTilde(file)
+
+	if filepath.IsAbs(file) {
+		return file, nil
+	}
+
+	if filepath.Base(file) == file && jd.JohnnyDecimalFilePattern.MatchString(file) {
+		jdObj, err := jd.Parse(file)
+		if err != nil {
+			return "", err
+		}
+		dir, err := jdObj.EnsureFolders(root)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, file), nil
+	}
+
+	return filepath.Join(root, file), nil
+}
+
+// schedule regenerates the index once the tree has been quiet for indexDebounce.
+func (w *indexWriter) schedule() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(indexDebounce, func() {
+		if err := w.update(); err != nil {
+			log.Warnf("Failed to update index %s: %v", w.path, err)
+		}
+	})
+}
+
+// removed schedules regenerating the index if path, just removed or renamed,
+// was one of the folders it lists. Files leaving the tree do not change it.
+func (w *indexWriter) removed(path string) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	listed := w.folders[filepath.Clean(path)]
+	w.mu.Unlock()
+
+	if listed {
+		w.schedule()
+	}
+}
+
+// stop cancels any pending regeneration.
+func (w *indexWriter) stop() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// update regenerates the index, writing it only if its content changed.
+func (w *indexWriter) update() error {
+	if w == nil {
+		return nil
+	}
+
+	w.updateMu.Lock()
+	defer w.updateMu.Unlock()
+
+	t, err := tree.Load(w.root)
+	if err != nil {
+		return err
+	}
+	data := []byte(index.Markdown(t))
+
+	folders := make(map[string]bool)
+	for _, area := range t.Areas {
+		folders[filepath.Clean(area.Path)] = true
+		for _, category := range area.Categories {
+			folders[filepath.Clean(category.Path)] = true
+			for _, id := range category.IDs {
+				folders[filepath.Clean(id.Path)] = true
+			}
+		}
+	}
+	w.mu.Lock()
+	w.folders = folders
+	w.mu.Unlock()
+
+	existing, err := os.ReadFile(w.path)
+	if err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return err
+	}
+	if err := utils.WriteFileAtomic(w.path, data, 0644); err != nil {
+		return err
+	}
+
+	log.Infof("Updated index %s", filepath.ToSlash(w.path))
+	return nil
+}
@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestIndexWriterRemoved checks that only folders listed in the index
+// schedule regenerating it when they go.
+func TestIndexWriterRemoved(t *testing.T) {
+	root := t.TempDir()
+	id := filepath.Join(root, "10-19 Life", "15 Travel", "15.23 Japan")
+	if err := os.MkdirAll(id, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(id, "15.23 ticket.pdf")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &indexWriter{root: root, path: filepath.Join(root, "index.md")}
+	if err := w.update(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.stop()
+
+	w.removed(file)
+	if w.timer != nil {
+		t.Fatal("removing a file scheduled an update")
+	}
+	w.removed(id)
+	if w.timer == nil {
+		t.Fatal("removing an ID folder did not schedule an update")
+	}
+}
+
+// TestIndexWriterConcurrent runs several updates at once; run with -race.
+func TestIndexWriterConcurrent(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "10-19 Life", "15 Travel"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &indexWriter{root: root, path: filepath.Join(root, "index.md")}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.update(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		t.Fatal("index is empty")
+	}
+}
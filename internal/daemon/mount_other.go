@@ -0,0 +1,10 @@
+//go:build !unix
+
+package daemon
+
+// isMountPoint reports whether dir is where a filesystem is mounted. Volume
+// roots are caught as filesystem roots, and folders volumes are mounted in
+// are not detected.
+func isMountPoint(dir string) bool {
+	return false
+}
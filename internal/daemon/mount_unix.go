@@ -0,0 +1,18 @@
+//go:build unix
+
+package daemon
+
+import (
+	"path/filepath"
+	"syscall"
+)
+
+// isMountPoint reports whether dir is where a filesystem is mounted: it lies
+// on a different device than its parent.
+func isMountPoint(dir string) bool {
+	var st, parent syscall.Stat_t
+	if syscall.Stat(dir, &st) != nil || syscall.Stat(filepath.Dir(dir), &parent) != nil {
+		return false
+	}
+	return st.Dev != parent.Dev
+}
@@ -0,0 +1,57 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// processPlaceholder files a file named with a placeholder ID p, such as
+// "15.xx Hotel booking.pdf", under the next free ID in its category. The new
+// ID folder is named after the file ("15.24 Hotel booking") and the file is
+// renamed to carry the real ID. Returns an error if the file could not be filed.
+func processPlaceholder(fullPath string, p *jd.Placeholder, root string, cfg *config.Config) error {
+	filename := filepath.Base(fullPath)
+	category, title := p.Category, p.Title
+
+	reserved, err := jd.ParseRanges(cfg.Reserved)
+	if err != nil {
+		log.Warnf("Invalid reserved ID ranges: %v", err)
+		return err
+	}
+
+	if cfg.DryRun {
+		next, err := jd.NextID(root, category, reserved)
+		if err != nil {
+			log.Warnf("Error allocating ID for %s: %v", filename, err)
+			return err
+		}
+		out := fmt.Sprintf("[dry run] Would file %s as %s in new ID folder %q", filepath.ToSlash(fullPath), p.Filename(next.ID), strings.TrimSpace(next.ID+" "+title))
+		// Log and send notification
+		log.Info(out)
+		utils.SendNotification(cfg.Notifications, "JDD", out)
+		return nil
+	}
+
+	jdObj, destDir, err := jd.Allocate(root, category, title, reserved)
+	if err != nil {
+		out := fmt.Sprintf("Error allocating ID for %s: %v", filename, err)
+		// Log and send notification
+		log.Error(out)
+		utils.SendNotification(cfg.Notifications, "JDD", out)
+		return err
+	}
+
+	if err := moveFile(fullPath, filepath.Join(destDir, p.Filename(jdObj.ID)), cfg); err != nil {
+		// Give the ID back; Remove only succeeds while the folder is still empty
+		_ = os.Remove(destDir)
+		return err
+	}
+	return nil
+}
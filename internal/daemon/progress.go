@@ -0,0 +1,92 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// ProgressFilename is where the daemon reports how far its initial scan has
+// got, in the state directory.
+const ProgressFilename = "progress.json"
+
+// Progress is how far the initial scan has got.
+type Progress struct {
+	Dirs     int64     `json:"dirs"`     // Directories visited
+	Files    int64     `json:"files"`    // Files looked at
+	Skipped  int64     `json:"skipped"`  // Files unchanged since the last scan
+	Moved    int64     `json:"moved"`    // Files moved
+	Expected int64     `json:"expected"` // Directories the last scan visited; 0 if unknown
+	Started  time.Time `json:"started"`
+	Updated  time.Time `json:"updated"`
+	Done     bool      `json:"done"`
+}
+
+// ETA estimates how long the scan has left from the rate directories have
+// been visited at so far. It is only known when the last scan's directory
+// count is, and the scan has not overtaken it.
+func (p Progress) ETA() (time.Duration, bool) {
+	if p.Done || p.Expected == 0 || p.Dirs == 0 || p.Dirs >= p.Expected {
+		return 0, false
+	}
+	elapsed := p.Updated.Sub(p.Started)
+	left := time.Duration(float64(elapsed) / float64(p.Dirs) * float64(p.Expected-p.Dirs))
+	return left.Round(time.Second), true
+}
+
+// String summarises the progress, e.g. "1200 folders, 34000 files (30000
+// unchanged), 15 moved, about 2m0s left".
+func (p Progress) String() string {
+	out := fmt.Sprintf("%d folders, %d files", p.Dirs, p.Files)
+	if p.Skipped > 0 {
+		out += fmt.Sprintf(" (%d unchanged)", p.Skipped)
+	}
+	out += fmt.Sprintf(", %d moved", p.Moved)
+	if p.Done {
+		return out + fmt.Sprintf(", done in %s", p.Updated.Sub(p.Started).Round(time.Millisecond))
+	}
+	if eta, ok := p.ETA(); ok {
+		out += fmt.Sprintf(", about %s left", eta)
+	}
+	return out
+}
+
+// ReadProgress returns the progress last reported by a daemon on root, or nil
+// if there is none.
+func ReadProgress(root string) (*Progress, error) {
+	data, err := os.ReadFile(filepath.Join(root, config.StateDir, ProgressFilename))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p Progress
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// writeProgress reports progress in the state directory of root. Failures
+// are only logged; they do not stop the scan.
+func writeProgress(root string, p Progress) {
+	if err := os.MkdirAll(filepath.Join(root, config.StateDir), 0755); err != nil {
+		log.Warnf("Failed to report progress: %v", err)
+		return
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		log.Warnf("Failed to report progress: %v", err)
+		return
+	}
+	if err := utils.WriteFileAtomic(filepath.Join(root, config.StateDir, ProgressFilename), data, 0644); err != nil {
+		log.Warnf("Failed to report progress: %v", err)
+	}
+}
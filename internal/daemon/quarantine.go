@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/quarantine"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// quarantineFile moves a file that could not be filed into the holding folder,
+// if one is configured, and records why. Files already in the holding folder,
+// hidden files and files still being written are left alone.
+func quarantineFile(fullPath string, root string, cfg *config.Config, c *classifier, reason string) {
+	if !quarantines(fullPath, cfg) {
+		return
+	}
+
+	if cfg.DryRun {
+		out := fmt.Sprintf("[dry run] Would quarantine %s: %s", filepath.ToSlash(fullPath), reason)
+		// Log and send notification
+		log.Info(out)
+		utils.SendNotification(cfg.Notifications, "JDD", out)
+		return
+	}
+
+	dir, err := quarantine.Dir(root, cfg.Quarantine, c.scheme)
+	if err != nil {
+		log.Errorf("Failed to create holding folder: %v", err)
+		return
+	}
+	if same, err := sameDir(filepath.Dir(fullPath), dir); err != nil || same {
+		return
+	}
+
+	dest, err := quarantine.Move(root, dir, fullPath, reason)
+	if err != nil {
+		out := fmt.Sprintf("Error quarantining %s: %v", filepath.Base(fullPath), err)
+		// Log and send notification
+		log.Error(out)
+		utils.SendNotification(cfg.Notifications, "JDD", out)
+		return
+	}
+
+	out := fmt.Sprintf("Quarantined %s -> %s: %s", filepath.ToSlash(fullPath), filepath.ToSlash(dest), reason)
+	// Log and send notification
+	log.Warn(out)
+	utils.SendNotification(cfg.Notifications, "JDD", out)
+}
+
+// quarantines reports whether a file that cannot be filed is quarantined,
+// rather than left where it is.
+func quarantines(fullPath string, cfg *config.Config) bool {
+	return cfg.Quarantine != "" && !quarantine.Skip(filepath.Base(fullPath))
+}
+
+// loose reports whether a file sits where files land rather than where they
+// are filed: directly in root or an inbox or, with Johnny Decimal, directly in
+// an area or category folder. Only loose files are quarantined for having no ID.
+func (c *classifier) loose(fullPath string) bool {
+	if c.inInbox(fullPath) {
+		return true
+	}
+	if !c.scheme.JohnnyDecimalFolders() {
+		return false
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(fullPath))
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(c.inboxes[0], dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	parts := strings.Split(rel, string(os.PathSeparator))
+	switch len(parts) {
+	case 1:
+		return jd.AreaFolderPattern.MatchString(parts[0])
+	case 2:
+		return jd.AreaFolderPattern.MatchString(parts[0]) && jd.CategoryFolderPattern.MatchString(parts[1])
+	}
+	return false
+}
+
+// sameDir reports whether two paths name the same directory.
+func sameDir(a, b string) (bool, error) {
+	absA, err := filepath.Abs(a)
+	if err != nil {
+		return false, err
+	}
+	absB, err := filepath.Abs(b)
+	if err != nil {
+		return false, err
+	}
+	return absA == absB, nil
+}
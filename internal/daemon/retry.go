@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/excluder"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/retry"
+	log "github.com/sirupsen/logrus"
+)
+
+// retryPollInterval is how often the daemon looks for files due to be retried.
+const retryPollInterval = time.Second
+
+// fileFailed handles a file that could not be filed. A transient error, such
+// as the file being locked by another program, is retried later with backoff;
+// anything else, or running out of attempts, quarantines the file.
+func fileFailed(fullPath string, root string, cfg *config.Config, c *classifier, rq *retry.Queue, reason string, err error) {
+	if err != nil && retry.Transient(err) {
+		// A folder may have vanished without the cache hearing of it yet
+		jd.ResetFolderCache()
+
+		delay, qerr := rq.Fail(fullPath, err)
+		if qerr != nil {
+			log.Warnf("Failed to save retry queue: %v", qerr)
+		}
+		if delay > 0 {
+			log.Warnf("Will retry %s in %s: %v", filepath.ToSlash(fullPath), delay, err)
+			return
+		}
+		reason = fmt.Sprintf("%s (gave up after %d attempts)", reason, cfg.RetryAttempts)
+		log.Errorf("Giving up on %s after %d attempts: %v", filepath.ToSlash(fullPath), cfg.RetryAttempts, err)
+	} else {
+		fileDone(fullPath, rq)
+	}
+	quarantineFile(fullPath, root, cfg, c, reason)
+}
+
+// fileDone drops a file from the retry queue once it no longer needs retrying.
+func fileDone(fullPath string, rq *retry.Queue) {
+	if err := rq.Done(fullPath); err != nil {
+		log.Warnf("Failed to save retry queue: %v", err)
+	}
+}
+
+// retryDue processes the queued files whose next attempt is due. Files that
+// have gone away in the meantime are dropped; if they were renamed, the new
+// name is picked up as a new file.
+func retryDue(root string, cfg *config.Config, ex *excluder.Excluder, c *classifier, rq *retry.Queue) {
+	for _, path := range rq.Due(time.Now()) {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			log.Debugf("No longer retrying %s: it is gone", filepath.ToSlash(path))
+			fileDone(path, rq)
+			continue
+		}
+		log.Infof("Retrying %s", filepath.ToSlash(path))
+		processFile(path, root, cfg, ex, c, rq)
+	}
+}
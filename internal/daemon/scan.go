@@ -0,0 +1,261 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/excluder"
+	"github.com/mahyarmirrashed/jdd/internal/retry"
+	"github.com/mahyarmirrashed/jdd/internal/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultScanWorkers is the number of directories scanned at once when none is configured.
+const DefaultScanWorkers = 8
+
+// progressInterval is how often the initial scan reports its progress.
+const progressInterval = 5 * time.Second
+
+// filesMoved counts the files moved, or that would be moved in dry-run mode,
+// since the daemon started.
+var filesMoved atomic.Int64
+
+// scanner walks a tree with a bounded pool of workers, each taking one
+// directory at a time: its files are processed and its subdirectories queued.
+type scanner struct {
+	ctx  context.Context // Cancelling it stops the scan
+	root string
+	cfg  *config.Config
+	ex   *excluder.Excluder
+	c    *classifier
+	rq   *retry.Queue
+	db   *state.DB // Nil to process every file
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	dirs   []string // Directories waiting for a worker
+	active int      // Directories being scanned
+	err    error    // First error; stops the scan
+
+	visited, files, skipped atomic.Int64
+}
+
+// initialScan walks the entire directory and ensures Johnny Decimal adherence,
+// scanning several directories at once. With a state database, files
+// unchanged and compliant since the last scan are skipped. Progress is logged
+// and written to the state directory as it goes, and answered live on the
+// control socket. Cancelling ctx stops the scan early with its error.
+func initialScan(ctx context.Context, root string, cfg *config.Config, ex *excluder.Excluder, c *classifier, rq *retry.Queue, db *state.DB) error {
+	s := &scanner{ctx: ctx, root: root, cfg: cfg, ex: ex, c: c, rq: rq, db: db, dirs: []string{root}}
+	s.cond = sync.NewCond(&s.mu)
+
+	start := Progress{Started: time.Now()}
+	if db != nil {
+		expected, err := db.Dirs()
+		if err != nil {
+			return err
+		}
+		start.Expected = expected
+	}
+	movedBefore := filesMoved.Load()
+	snapshot := func() Progress {
+		p := start
+		p.Dirs, p.Files, p.Skipped = s.visited.Load(), s.files.Load(), s.skipped.Load()
+		p.Moved = filesMoved.Load() - movedBefore
+		p.Updated = time.Now()
+		return p
+	}
+	scanProgress.Store(&snapshot)
+
+	// Report progress until the workers are done
+	stop := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p := snapshot()
+				log.Infof("Initial scan: %s", p)
+				writeProgress(root, p)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	workers := cfg.ScanWorkers
+	if workers <= 0 {
+		workers = DefaultScanWorkers
+	}
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work()
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-reported
+
+	p := snapshot()
+	p.Done = true
+	final := func() Progress { return p }
+	scanProgress.Store(&final)
+	writeProgress(root, p)
+	if s.err != nil {
+		return s.err
+	}
+
+	log.Infof("Initial scan: %s", p)
+	if db == nil {
+		return nil
+	}
+	return db.Finish(p.Dirs)
+}
+
+// work scans queued directories until there are none left and no other
+// worker can queue more, or the scan failed.
+func (s *scanner) work() {
+	for {
+		s.mu.Lock()
+		for len(s.dirs) == 0 && s.active > 0 && s.err == nil {
+			s.cond.Wait()
+		}
+		if len(s.dirs) == 0 || s.err != nil {
+			s.mu.Unlock()
+			s.cond.Broadcast()
+			return
+		}
+		dir := s.dirs[len(s.dirs)-1]
+		s.dirs = s.dirs[:len(s.dirs)-1]
+		s.active++
+		s.mu.Unlock()
+
+		subdirs, err := s.scanDir(dir)
+
+		s.mu.Lock()
+		s.active--
+		s.dirs = append(s.dirs, subdirs...)
+		if err != nil && s.err == nil {
+			s.err = err
+		}
+		s.mu.Unlock()
+		s.cond.Broadcast()
+	}
+}
+
+// scanDir processes the files in dir and returns its subdirectories. With a
+// state database, a directory unchanged since the last scan, whose files were
+// all compliant, is not listed: its subdirectories are known.
+func (s *scanner) scanDir(dir string) ([]string, error) {
+	// Taken before listing, so that a change made while listing shows next time
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if s.db != nil {
+		known, err := s.db.UnchangedDir(dir, info)
+		if err != nil {
+			return nil, err
+		}
+		if known != nil {
+			s.visited.Add(1)
+			s.files.Add(int64(known.Files))
+			s.skipped.Add(int64(known.Files))
+			return s.subdirs(dir, known.Subdirs), nil
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var recorded map[string]state.Entry
+	if s.db != nil {
+		if recorded, err = s.db.Files(dir); err != nil {
+			return nil, err
+		}
+	}
+	s.visited.Add(1)
+
+	l := state.Listing{Info: info, Compliant: true}
+	for _, entry := range entries {
+		if err := s.ctx.Err(); err != nil {
+			return nil, err
+		}
+		if entry.IsDir() {
+			l.Subdirs = append(l.Subdirs, entry.Name())
+			continue
+		}
+		s.files.Add(1)
+		l.Files++
+		compliant, err := s.scanFile(filepath.Join(dir, entry.Name()), entry, recorded)
+		if err != nil {
+			return nil, err
+		}
+		l.Compliant = l.Compliant && compliant
+		delete(recorded, entry.Name())
+	}
+
+	if s.db != nil {
+		for name := range recorded {
+			l.Gone = append(l.Gone, name)
+		}
+		if err := s.db.RecordDir(dir, l); err != nil {
+			return nil, err
+		}
+	}
+	return s.subdirs(dir, l.Subdirs), nil
+}
+
+// subdirs returns the paths of the named subdirectories of dir to scan. Those
+// marked to be left alone are not entered; markers are looked for every time,
+// as adding or removing one inside a folder does not change the folder above.
+func (s *scanner) subdirs(dir string, names []string) []string {
+	var paths []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if excluder.Marked(path) {
+			log.Debugf("Excluded: %s", path)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// scanFile processes one file, unless the state database shows it has not
+// changed since the last scan, going by what was recorded for the files in
+// its directory. It returns whether the file is compliant: where it belongs,
+// or with nothing to be done with it.
+func (s *scanner) scanFile(path string, entry os.DirEntry, recorded map[string]state.Entry) (bool, error) {
+	// The folders above were checked for markers on the way down. jdd's own
+	// files, the state database among them, are excluded and not tracked.
+	if s.ex.Skip(path) {
+		log.Debugf("Excluded: %s", path)
+		return true, nil
+	}
+	if s.db == nil {
+		return processIncluded(path, s.root, s.cfg, s.c, s.rq), nil
+	}
+
+	if info, err := entry.Info(); err == nil {
+		if old, ok := recorded[entry.Name()]; ok && old.Unchanged(info) {
+			s.skipped.Add(1)
+			return true, nil
+		}
+	}
+
+	compliant := processIncluded(path, s.root, s.cfg, s.c, s.rq)
+	return compliant, s.db.Record(path, compliant)
+}
@@ -0,0 +1,198 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/excluder"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/retry"
+	"github.com/mahyarmirrashed/jdd/internal/state"
+	"github.com/mahyarmirrashed/jdd/internal/testutil"
+)
+
+// scanTimeout bounds a scan in the tests, so that one that never terminates
+// fails rather than hangs.
+const scanTimeout = 10 * time.Second
+
+// runScan runs the initial scan of root with several workers, keeping state in
+// db if it is not nil, and returns its error.
+func runScan(t *testing.T, ctx context.Context, root string, db *state.DB) error {
+	t.Helper()
+	cfg := &config.Config{Root: root, ScanWorkers: 8}
+	ex, err := excluder.New([]string{config.StateDir + "/**"}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newClassifier(root, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rq, err := retry.Load(root, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jd.EnableFolderCache(root)
+	t.Cleanup(jd.DisableFolderCache)
+
+	done := make(chan error, 1)
+	go func() { done <- initialScan(ctx, root, cfg, ex, c, rq, db) }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(scanTimeout):
+		t.Fatal("initial scan did not terminate")
+		return nil
+	}
+}
+
+// makeTree creates dirs folders under root, each holding files files that
+// all belong to IDs of category 15, and returns the paths of the files.
+func makeTree(t *testing.T, root string, dirs, files int) []string {
+	t.Helper()
+	var paths []string
+	for d := range dirs {
+		dir := filepath.Join(root, fmt.Sprintf("inbox %d", d), "nested")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for f := range files {
+			path := filepath.Join(dir, fmt.Sprintf("15.%02d file %d-%d.txt", f%5, d, f))
+			if err := os.WriteFile(path, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// TestInitialScanParallel scans a tree with several workers and checks that
+// every file is filed and every folder created once.
+func TestInitialScanParallel(t *testing.T) {
+	root := t.TempDir()
+	paths := makeTree(t, root, 20, 10)
+
+	if err := runScan(t, context.Background(), root, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		name := filepath.Base(path)
+		want := filepath.Join(root, "10-19", "15", name[:5], name)
+		if _, err := os.Stat(want); err != nil {
+			t.Errorf("%s not filed: %v", name, err)
+		}
+	}
+	testutil.AssertEntries(t, filepath.Join(root, "10-19"), 1)
+	testutil.AssertEntries(t, filepath.Join(root, "10-19", "15"), 5)
+}
+
+// TestInitialScanError checks that a scan that fails stops all its workers
+// and reports the failure.
+func TestInitialScanError(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "missing")
+
+	if err := runScan(t, context.Background(), root, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got %v, want a missing directory error", err)
+	}
+}
+
+// TestInitialScanCancel checks that a cancelled scan stops without filing
+// anything.
+func TestInitialScanCancel(t *testing.T) {
+	root := t.TempDir()
+	paths := makeTree(t, root, 5, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := runScan(t, ctx, root, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s moved by a cancelled scan", filepath.Base(path))
+		}
+	}
+}
+
+// TestInitialScanState scans a tree twice with a state database and checks
+// that the second scan skips the folders left as they were, counting files
+// without an ID inside the tree as needing nothing done.
+func TestInitialScanState(t *testing.T) {
+	root := t.TempDir()
+	for _, path := range []string{
+		filepath.Join(root, "10-19", "15", "15.23", "15.23 ticket.pdf"),
+		filepath.Join(root, "10-19", "15", "15.23", "notes.txt"),
+		filepath.Join(root, "10-19", "15", "15.24", "15.24 visa.pdf"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db, err := state.Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Folders changed moments ago are listed again, in case a change hides in the same tick
+	backdate := func() {
+		t.Helper()
+		old := time.Now().Add(-time.Hour)
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			return os.Chtimes(path, old, old)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	scan := func() *Progress {
+		t.Helper()
+		backdate()
+		if err := runScan(t, context.Background(), root, db); err != nil {
+			t.Fatal(err)
+		}
+		p, err := ReadProgress(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	if p := scan(); p.Skipped != 0 {
+		t.Fatalf("first scan: %s", p)
+	}
+	if p := scan(); p.Skipped != 3 {
+		t.Fatalf("second scan: %s", p)
+	}
+	dir := filepath.Join(root, "10-19", "15", "15.23")
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if known, err := db.UnchangedDir(dir, info); err != nil || known == nil || known.Files != 2 {
+		t.Fatalf("got %+v, %v, want %s recorded with its two files", known, err, dir)
+	}
+
+	// A new file changes its folder, which is listed again
+	if err := os.WriteFile(filepath.Join(root, "10-19", "15", "15.24", "15.24 photo.jpg"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if p := scan(); p.Skipped != 3 {
+		t.Fatalf("third scan: %s", p)
+	}
+}
@@ -0,0 +1,39 @@
+package daemon
+
+import (
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/state"
+	log "github.com/sirupsen/logrus"
+)
+
+// openState opens the state database under root. It is emptied, so that every
+// file is processed, when a full rescan is asked for or the settings that
+// decide where files belong changed since the last run.
+func openState(root string, cfg *config.Config) (*state.DB, error) {
+	db, err := state.Open(root)
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := state.Fingerprint(cfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	last, err := db.Config()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if cfg.FullRescan || fp != last {
+		if !cfg.FullRescan && last != "" {
+			log.Info("Configuration changed since the last run, rescanning every file")
+		}
+		if err := db.Reset(fp); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
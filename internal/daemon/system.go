@@ -0,0 +1,46 @@
+package daemon
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+)
+
+// validateSystems checks the configured system codes and the primary system.
+func validateSystems(cfg *config.Config) error {
+	for code := range cfg.Systems {
+		if !jd.SystemPattern.MatchString(code) {
+			return fmt.Errorf("invalid system code %q, expected a letter and two digits like P01", code)
+		}
+	}
+	if cfg.PrimarySystem != "" {
+		if _, ok := cfg.Systems[cfg.PrimarySystem]; !ok {
+			return fmt.Errorf("primary system %s has no root directory", cfg.PrimarySystem)
+		}
+	}
+	return nil
+}
+
+// systemRoot returns the root of the JD system a file at fullPath belongs to.
+// A file naming a system goes to that system. A file without one stays in the
+// system whose tree it is already in, and otherwise goes to the primary
+// system, or to root if there is none.
+func systemRoot(system string, fullPath string, root string, cfg *config.Config) (string, error) {
+	if system != "" {
+		return cfg.SystemRoot(root, system)
+	}
+
+	for code := range cfg.Systems {
+		dir, err := cfg.SystemRoot(root, code)
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(fullPath, dir+string(filepath.Separator)) {
+			return dir, nil
+		}
+	}
+	return cfg.SystemRoot(root, "")
+}
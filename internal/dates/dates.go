@@ -0,0 +1,114 @@
+package dates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date sources, tried in the configured order.
+const (
+	SourceFilename = "filename" // A date written in the filename, e.g. "2024-03-15" or "202403"
+	SourceMetadata = "metadata" // EXIF DateTimeOriginal for JPEG images, CreationDate for PDFs
+	SourceMtime    = "mtime"    // The file's modification time
+)
+
+// DefaultSources is the order sources are tried in when none is configured.
+var DefaultSources = []string{SourceFilename, SourceMetadata, SourceMtime}
+
+// filenamePattern matches a year and month in a filename, optionally
+// separated by "-", "_" or "." and followed by a day, e.g. "2024-03-15",
+// "2024_03" or "20240315".
+var filenamePattern = regexp.MustCompile(`(?:^|\D)((?:19|20)\d{2})[-_.]?(0[1-9]|1[0-2])(?:[-_.]?(0[1-9]|[12]\d|3[01]))?(?:\D|$)`)
+
+// ValidateSources checks that every source is known.
+func ValidateSources(sources []string) error {
+	for _, source := range sources {
+		switch source {
+		case SourceFilename, SourceMetadata, SourceMtime:
+		default:
+			return fmt.Errorf("unknown date source %q, expected %s", source, strings.Join(DefaultSources, ", "))
+		}
+	}
+	return nil
+}
+
+// Of returns the date of the file at path from the first source that has
+// one, trying sources in order, or DefaultSources if there are none.
+func Of(path string, sources []string) (time.Time, bool) {
+	if len(sources) == 0 {
+		sources = DefaultSources
+	}
+
+	for _, source := range sources {
+		var t time.Time
+		var ok bool
+		switch source {
+		case SourceFilename:
+			t, ok = FromFilename(filepath.Base(path))
+		case SourceMetadata:
+			t, ok = FromMetadata(path)
+		case SourceMtime:
+			if info, err := os.Stat(path); err == nil {
+				t, ok = info.ModTime(), true
+			}
+		}
+		if ok {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// FromFilename returns the date written in a filename. A missing day is the first.
+func FromFilename(filename string) (time.Time, bool) {
+	m := filenamePattern.FindStringSubmatch(filename)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day := 1
+	if m[3] != "" {
+		day, _ = strconv.Atoi(m[3])
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.Local), true
+}
+
+// FromMetadata returns the date embedded in a JPEG or PDF file.
+func FromMetadata(path string) (time.Time, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return fromEXIF(path)
+	case ".pdf":
+		return fromPDF(path)
+	default:
+		return time.Time{}, false
+	}
+}
+
+// Bucket granularities for filing by date.
+const (
+	BucketYear  = "year"  // "2024"
+	BucketMonth = "month" // "2024-03"
+)
+
+// BucketPattern matches a date bucket folder name like "2024" or "2024-03".
+var BucketPattern = regexp.MustCompile(`^\d{4}(?:-\d{2})?$`)
+
+// Bucket returns the folder name for t at the given granularity.
+func Bucket(t time.Time, granularity string) (string, error) {
+	switch granularity {
+	case BucketYear:
+		return t.Format("2006"), nil
+	case BucketMonth:
+		return t.Format("2006-01"), nil
+	default:
+		return "", fmt.Errorf("unknown date bucket %q, expected %s or %s", granularity, BucketYear, BucketMonth)
+	}
+}
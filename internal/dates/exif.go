@@ -0,0 +1,116 @@
+package dates
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+)
+
+// EXIF tags read from a JPEG.
+const (
+	tagDateTime         = 0x0132 // IFD0: when the file was last changed
+	tagExifIFD          = 0x8769 // IFD0: offset of the Exif IFD
+	tagDateTimeOriginal = 0x9003 // Exif IFD: when the picture was taken
+)
+
+// exifLayout is the layout of EXIF dates.
+const exifLayout = "2006:01:02 15:04:05"
+
+// fromEXIF returns DateTimeOriginal, or else DateTime, from a JPEG's EXIF data.
+func fromEXIF(path string) (time.Time, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	tiff, ok := exifSegment(bufio.NewReader(f))
+	if !ok || len(tiff) < 8 {
+		return time.Time{}, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return time.Time{}, false
+	}
+
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+	if offset, ok := ifd0[tagExifIFD]; ok {
+		exif := readIFD(tiff, order, order.Uint32(offset))
+		if t, ok := exifTime(tiff, order, exif[tagDateTimeOriginal]); ok {
+			return t, true
+		}
+	}
+	return exifTime(tiff, order, ifd0[tagDateTime])
+}
+
+// exifSegment returns the TIFF data of the APP1 Exif segment of a JPEG stream.
+func exifSegment(r *bufio.Reader) ([]byte, bool) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil, false
+	}
+
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil || marker[0] != 0xFF {
+			return nil, false
+		}
+		// Start of scan: image data follows, there is no more metadata
+		if marker[1] == 0xDA {
+			return nil, false
+		}
+
+		size := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if size < 0 {
+			return nil, false
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, false
+		}
+		if marker[1] == 0xE1 && len(data) > 6 && string(data[:6]) == "Exif\x00\x00" {
+			return data[6:], true
+		}
+	}
+}
+
+// readIFD returns the value fields of the entries of the IFD at offset, by tag.
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16][]byte {
+	entries := make(map[uint16][]byte)
+	if int(offset)+2 > len(tiff) {
+		return entries
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		start := int(offset) + 2 + i*12
+		if start+12 > len(tiff) {
+			break
+		}
+		entry := tiff[start : start+12]
+		entries[order.Uint16(entry)] = entry[8:12]
+	}
+	return entries
+}
+
+// exifTime parses the date string an entry's value field points to.
+func exifTime(tiff []byte, order binary.ByteOrder, value []byte) (time.Time, bool) {
+	if value == nil {
+		return time.Time{}, false
+	}
+
+	offset := int(order.Uint32(value))
+	if offset+len(exifLayout) > len(tiff) {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(exifLayout, string(tiff[offset:offset+len(exifLayout)]), time.Local)
+	return t, err == nil
+}
@@ -0,0 +1,48 @@
+package dates
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"time"
+)
+
+// pdfScanSize is how much of the start and end of a PDF is searched for its
+// CreationDate, which usually sits in the document information near either end.
+const pdfScanSize = 1 << 20
+
+// pdfCreationDate matches an uncompressed CreationDate entry such as
+// "/CreationDate (D:20240315103000+01'00')"; only the date is used.
+var pdfCreationDate = regexp.MustCompile(`/CreationDate\s*\(D:(\d{8})`)
+
+// fromPDF returns the CreationDate of a PDF.
+func fromPDF(path string) (time.Time, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	offsets := []int64{0}
+	if info.Size() > pdfScanSize {
+		offsets = append(offsets, info.Size()-pdfScanSize)
+	}
+
+	buf := make([]byte, pdfScanSize)
+	for _, offset := range offsets {
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return time.Time{}, false
+		}
+		if m := pdfCreationDate.FindSubmatch(buf[:n]); m != nil {
+			t, err := time.ParseInLocation("20060102", string(m[1]), time.Local)
+			return t, err == nil
+		}
+	}
+	return time.Time{}, false
+}
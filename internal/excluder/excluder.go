@@ -1,15 +1,18 @@
 package excluder
 
 import (
+	"fmt"
 	"path/filepath"
 
 	"github.com/gobwas/glob"
 )
 
-// Excluder matches file paths against a list of glob patterns.
+// Excluder matches file paths against a list of glob patterns and the opt-out
+// markers users put on files and folders.
 type Excluder struct {
-	globs []glob.Glob
-	root  string
+	globs    []glob.Glob
+	patterns []string // Source of each glob, for explaining matches
+	root     string
 }
 
 // New creates an Excluder from a list of glob patterns and the root directory.
@@ -24,12 +27,50 @@ func New(patterns []string, root string) (*Excluder, error) {
 		globs = append(globs, g)
 	}
 
-	return &Excluder{globs: globs, root: root}, nil
+	return &Excluder{globs: globs, patterns: append([]string(nil), patterns...), root: root}, nil
 }
 
-// IsExcluded returns true if the given path matches any exclude pattern.
+// AddPath excludes a single path, e.g. a file the daemon writes itself.
+func (e *Excluder) AddPath(path string) error {
+	rel, err := filepath.Rel(e.root, path)
+	if err != nil {
+		return err
+	}
+
+	pat := glob.QuoteMeta(filepath.ToSlash(rel))
+	g, err := glob.Compile(pat, '/')
+	if err != nil {
+		return err
+	}
+	e.globs = append(e.globs, g)
+	e.patterns = append(e.patterns, pat)
+	return nil
+}
+
+// IsExcluded returns true if the given path matches any exclude pattern, or
+// it or a folder it is in carries an opt-out marker.
 // The path is made relative to the root before matching.
 func (e *Excluder) IsExcluded(path string) bool {
+	return e.Reason(path) != ""
+}
+
+// Skip reports whether a walk down from root should skip path: it matches an
+// exclude pattern or carries an opt-out marker itself. Unlike IsExcluded, the
+// folders above path are not checked, as the walk skipped marked ones.
+func (e *Excluder) Skip(path string) bool {
+	return e.pattern(path) != "" || Marked(path)
+}
+
+// Reason returns why the given path is excluded, or nothing if it is not.
+func (e *Excluder) Reason(path string) string {
+	if reason := e.pattern(path); reason != "" {
+		return reason
+	}
+	return Marker(e.root, path)
+}
+
+// pattern returns which exclude pattern the given path matches, if any.
+func (e *Excluder) pattern(path string) string {
 	rel, err := filepath.Rel(e.root, path)
 	if err != nil {
 		// fallback: just use the original path
@@ -37,10 +78,10 @@ func (e *Excluder) IsExcluded(path string) bool {
 	}
 	rel = filepath.ToSlash(rel) // Ensure '/' as separator
 
-	for _, g := range e.globs {
+	for i, g := range e.globs {
 		if g.Match(rel) {
-			return true
+			return fmt.Sprintf("matches exclude pattern %q", e.patterns[i])
 		}
 	}
-	return false
+	return ""
 }
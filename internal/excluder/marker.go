@@ -0,0 +1,91 @@
+package excluder
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Markers users can put on files and folders to keep jdd away from them,
+// without touching the config.
+const (
+	KeepFilename = ".jdkeep"         // A file of this name keeps jdd out of its folder and everything below
+	IgnoreAttr   = "user.jdd.ignore" // An extended attribute that keeps jdd away from a file or folder
+	NoJDDToken   = "#nojdd"          // A token in a file or folder name that keeps jdd away from it
+)
+
+// Marker returns which opt-out marker protects path, or nothing if none does.
+// The path itself and every folder it is in up to and including root are
+// checked; a path outside root, e.g. in an inbox, is checked with its own
+// folder only.
+func Marker(root, path string) string {
+	for _, p := range markable(root, path) {
+		if reason := marked(p); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
+
+// Marked reports whether path itself carries an opt-out marker. A walk down
+// from root that skips marked folders, and everything below them, need check
+// nothing more.
+func Marked(path string) bool {
+	return marked(path) != ""
+}
+
+// SubtreeMarker returns which opt-out marker protects path or anything inside
+// it, for operations that move or rename a whole folder: a marker found by
+// Marker, or one on anything below path.
+func SubtreeMarker(root, path string) (string, error) {
+	if reason := Marker(root, path); reason != "" {
+		return reason, nil
+	}
+
+	var reason string
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != path {
+			reason = marked(p)
+		}
+		if reason != "" {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return reason, err
+}
+
+// marked returns which opt-out marker path itself carries, if any.
+func marked(path string) string {
+	if strings.Contains(strings.ToLower(filepath.Base(path)), NoJDDToken) {
+		return fmt.Sprintf("%s has %s in its name", filepath.ToSlash(path), NoJDDToken)
+	}
+	if hasIgnoreAttr(path) {
+		return fmt.Sprintf("%s has the %s attribute", filepath.ToSlash(path), IgnoreAttr)
+	}
+	if _, err := os.Lstat(filepath.Join(path, KeepFilename)); err == nil {
+		return fmt.Sprintf("%s holds a %s file", filepath.ToSlash(path), KeepFilename)
+	}
+	return ""
+}
+
+// markable returns path and the folders it is in, up to and including root,
+// innermost first.
+func markable(root, path string) []string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return []string{path, filepath.Dir(path)}
+	}
+
+	var paths []string
+	for rel != "." && rel != string(filepath.Separator) {
+		paths = append(paths, filepath.Join(root, rel))
+		rel = filepath.Dir(rel)
+	}
+	return append(paths, root)
+}
@@ -0,0 +1,131 @@
+package excluder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// makeTree creates the files, or folders ending in "/", under root.
+func makeTree(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if strings.HasSuffix(path, "/") {
+			if err := os.MkdirAll(full, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMarker(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root,
+		"10-19/15/15.23 Japan/15.23 ticket.pdf",
+		"10-19/16/.jdkeep",
+		"10-19/16/16.01 Lease/16.01 lease.pdf",
+		"10-19/17 Old #NoJDD/17.01 notes.txt",
+		"10-19/15/15.24 visa #nojdd.pdf",
+	)
+	inbox := t.TempDir()
+	makeTree(t, inbox, "report.pdf", "Kept/.jdkeep", "Kept/report.pdf", "Kept/Nested/report.pdf")
+
+	tests := []struct {
+		path string
+		want string // Marked path named in the reason; empty if none
+	}{
+		{"10-19/15/15.23 Japan/15.23 ticket.pdf", ""},
+		{"10-19/15/15.23 Japan", ""},
+		{"10-19/16", "10-19/16"},
+		{"10-19/16/16.01 Lease/16.01 lease.pdf", "10-19/16"},
+		{"10-19/17 Old #NoJDD/17.01 notes.txt", "10-19/17 Old #NoJDD"},
+		{"10-19/15/15.24 visa #nojdd.pdf", "10-19/15/15.24 visa #nojdd.pdf"},
+	}
+	for _, tt := range tests {
+		got := Marker(root, filepath.Join(root, filepath.FromSlash(tt.path)))
+		if tt.want == "" {
+			if got != "" {
+				t.Errorf("Marker(%s) = %q, want none", tt.path, got)
+			}
+		} else if !strings.HasPrefix(got, filepath.ToSlash(filepath.Join(root, filepath.FromSlash(tt.want)))+" ") {
+			t.Errorf("Marker(%s) = %q, want one naming %s", tt.path, got, tt.want)
+		}
+	}
+
+	// Outside root only the path and its own folder count
+	if got := Marker(root, filepath.Join(inbox, "report.pdf")); got != "" {
+		t.Errorf("got %q for an unmarked inbox file", got)
+	}
+	if got := Marker(root, filepath.Join(inbox, "Kept", "report.pdf")); !strings.Contains(got, KeepFilename) {
+		t.Errorf("got %q for a file in a marked inbox folder", got)
+	}
+	if got := Marker(root, filepath.Join(inbox, "Kept", "Nested", "report.pdf")); got != "" {
+		t.Errorf("got %q two folders below the marker", got)
+	}
+}
+
+// TestMarkerRoot honours a marker on root itself, for root and everything in it.
+func TestMarkerRoot(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "10-19/15/15.23 Japan/15.23 ticket.pdf")
+	ticket := filepath.Join(root, "10-19", "15", "15.23 Japan", "15.23 ticket.pdf")
+	if got := Marker(root, ticket); got != "" {
+		t.Fatalf("got %q before root was marked", got)
+	}
+
+	makeTree(t, root, KeepFilename)
+	for _, path := range []string{root, filepath.Join(root, "10-19"), ticket} {
+		if got := Marker(root, path); !strings.Contains(got, filepath.ToSlash(root)+" holds a "+KeepFilename) {
+			t.Errorf("Marker(%s) = %q, want root's marker", path, got)
+		}
+	}
+
+	ex, err := New(nil, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ex.IsExcluded(ticket) {
+		t.Error("a file under a marked root is not excluded")
+	}
+	// A walk down from root has checked root already
+	if ex.Skip(ticket) {
+		t.Error("a walk skips an unmarked file")
+	}
+}
+
+func TestSubtreeMarker(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root,
+		"10-19/15/15.23 Japan/15.23 ticket.pdf",
+		"10-19/15/15.24 Korea/Scans #nojdd/scan.pdf",
+		"10-19/16/.jdkeep",
+	)
+	tests := []struct {
+		path   string
+		marked bool
+	}{
+		{"10-19/15/15.23 Japan", false},
+		{"10-19/15/15.24 Korea", true},
+		{"10-19/15", true},
+		{"10-19", true},
+		{"10-19/16", true},
+	}
+	for _, tt := range tests {
+		got, err := SubtreeMarker(root, filepath.Join(root, filepath.FromSlash(tt.path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (got != "") != tt.marked {
+			t.Errorf("SubtreeMarker(%s) = %q, want marked %v", tt.path, got, tt.marked)
+		}
+	}
+}
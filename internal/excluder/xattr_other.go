@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd)
+
+package excluder
+
+// hasIgnoreAttr reports whether path carries the ignore attribute, which this
+// platform has no way to set.
+func hasIgnoreAttr(path string) bool {
+	return false
+}
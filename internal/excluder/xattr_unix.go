@@ -0,0 +1,12 @@
+//go:build linux || darwin || freebsd || netbsd
+
+package excluder
+
+import "golang.org/x/sys/unix"
+
+// hasIgnoreAttr reports whether path carries the ignore attribute. Symbolic
+// links are not followed.
+func hasIgnoreAttr(path string) bool {
+	_, err := unix.Lgetxattr(path, IgnoreAttr, nil)
+	return err == nil
+}
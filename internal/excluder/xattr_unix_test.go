@@ -0,0 +1,31 @@
+//go:build linux || darwin || freebsd || netbsd
+
+package excluder
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestIgnoreAttr(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "10-19/15/15.23 ticket.pdf")
+	dir := filepath.Join(root, "10-19", "15")
+	ticket := filepath.Join(dir, "15.23 ticket.pdf")
+
+	if err := unix.Setxattr(dir, IgnoreAttr, []byte("1"), 0); err != nil {
+		t.Skipf("extended attributes unsupported here: %v", err)
+	}
+	if !Marked(dir) {
+		t.Error("a folder with the attribute is not marked")
+	}
+	if got := Marker(root, ticket); !strings.Contains(got, IgnoreAttr) {
+		t.Errorf("got %q for a file in a folder with the attribute", got)
+	}
+	if Marked(ticket) {
+		t.Error("the attribute on a folder marks the files in it themselves")
+	}
+}
@@ -0,0 +1,246 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mahyarmirrashed/jdd/internal/excluder"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/tree"
+)
+
+// ImportOptions controls how Import applies an index to a root directory.
+type ImportOptions struct {
+	DryRun bool // If true, report changes without touching the filesystem
+	Rename bool // If true, rename folders whose number matches but whose name differs
+}
+
+// Change actions reported by Import.
+const (
+	ActionCreate    = "create"    // Folder did not exist and was created
+	ActionRename    = "rename"    // Folder existed under another name and was renamed
+	ActionMismatch  = "mismatch"  // Folder exists under another name and was left alone
+	ActionProtected = "protected" // Folder exists under another name but holds an opt-out marker, so was not renamed
+	ActionMisplaced = "misplaced" // Folder exists in another area or category and was left alone
+)
+
+// Change describes a single folder that Import created, renamed or left alone.
+type Change struct {
+	Action string
+	From   string // Existing path, for renames, mismatches and misplaced folders
+	To     string // Path declared by the index
+	Reason string // Opt-out marker that kept a folder from being renamed
+}
+
+// Read parses an index previously written in the yaml or json format.
+func Read(r io.Reader, format string) (*tree.Tree, error) {
+	t := &tree.Tree{}
+
+	switch strings.ToLower(format) {
+	case "json":
+		if err := json.NewDecoder(r).Decode(t); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+	case "yaml", "yml":
+		if err := yaml.NewDecoder(r).Decode(t); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("cannot import index format %q (expected json or yaml)", format)
+	}
+
+	return t, validate(t)
+}
+
+// Import creates every area, category and ID folder declared in want under
+// root. Existing folders are matched by number, so running it again is a
+// no-op. A category or ID that already exists in the wrong place is reported
+// rather than created a second time.
+func Import(root string, want *tree.Tree, opts ImportOptions) ([]Change, error) {
+	have, err := tree.Load(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every category and ID in the tree, wherever it is
+	categories := make(map[string]*tree.Category)
+	ids := make(map[string]*tree.ID)
+	for _, area := range have.Areas {
+		for _, category := range area.Categories {
+			if categories[category.Number] == nil {
+				categories[category.Number] = category
+			}
+			for _, id := range category.IDs {
+				if ids[id.Number] == nil {
+					ids[id.Number] = id
+				}
+			}
+		}
+	}
+
+	var changes []Change
+	for _, area := range want.Areas {
+		existingArea := have.Area(area.Number)
+		var existing string
+		if existingArea != nil {
+			existing = existingArea.Path
+		}
+
+		areaPath, change, err := ensureFolder(root, root, existing, area.Label(), area.Name != "", opts)
+		if err != nil {
+			return changes, err
+		}
+		changes = appendChange(changes, change)
+
+		for _, category := range area.Categories {
+			existingCategory := existingArea.Category(category.Number)
+			var categoryPath string
+			if elsewhere := categories[category.Number]; existingCategory == nil && elsewhere != nil {
+				changes = append(changes, Change{Action: ActionMisplaced, From: elsewhere.Path, To: filepath.Join(areaPath, category.Label())})
+				existingCategory, categoryPath = elsewhere, elsewhere.Path
+			} else {
+				existing = ""
+				if existingCategory != nil {
+					existing = existingCategory.Path
+				}
+
+				categoryPath, change, err = ensureFolder(root, areaPath, existing, category.Label(), category.Name != "", opts)
+				if err != nil {
+					return changes, err
+				}
+				changes = appendChange(changes, change)
+			}
+
+			for _, id := range category.IDs {
+				existingID := existingCategory.ID(id.Number)
+				if elsewhere := ids[id.Number]; existingID == nil && elsewhere != nil {
+					changes = append(changes, Change{Action: ActionMisplaced, From: elsewhere.Path, To: filepath.Join(categoryPath, id.Label())})
+					continue
+				}
+				existing = ""
+				if existingID != nil {
+					existing = existingID.Path
+				}
+
+				_, change, err := ensureFolder(root, categoryPath, existing, id.Label(), id.Name != "", opts)
+				if err != nil {
+					return changes, err
+				}
+				changes = appendChange(changes, change)
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// ensureFolder makes sure a folder named label exists in parent, given the
+// path of an existing folder with the same number (or "" if there is none).
+// It returns the folder's path and the change made, if any. An index entry
+// that is not named matches an existing folder whatever its name. Folders
+// holding an opt-out marker, or inside one that does, are never renamed.
+func ensureFolder(root, parent, existing, label string, named bool, opts ImportOptions) (string, *Change, error) {
+	want := filepath.Join(parent, label)
+
+	if existing == "" {
+		if !opts.DryRun {
+			if err := os.Mkdir(want, 0755); err != nil && !os.IsExist(err) {
+				return "", nil, err
+			}
+		}
+		return want, &Change{Action: ActionCreate, To: want}, nil
+	}
+
+	// Compare against the existing folder's location under parent, which may
+	// itself be a renamed (or, in dry-run mode, not yet renamed) folder.
+	current := existing
+	existing = filepath.Join(parent, filepath.Base(existing))
+	if filepath.Base(existing) == label || !named {
+		return existing, nil, nil
+	}
+
+	if !opts.Rename {
+		return existing, &Change{Action: ActionMismatch, From: existing, To: want}, nil
+	}
+
+	if !opts.DryRun {
+		current = existing
+	}
+	reason, err := excluder.SubtreeMarker(root, current)
+	if err != nil {
+		return "", nil, err
+	}
+	if reason != "" {
+		return existing, &Change{Action: ActionProtected, From: existing, To: want, Reason: reason}, nil
+	}
+
+	if !opts.DryRun {
+		if err := os.Rename(existing, want); err != nil {
+			return "", nil, err
+		}
+	}
+	return want, &Change{Action: ActionRename, From: existing, To: want}, nil
+}
+
+// appendChange appends change to changes if it is not nil.
+func appendChange(changes []Change, change *Change) []Change {
+	if change == nil {
+		return changes
+	}
+	return append(changes, *change)
+}
+
+// validate checks that every number in the index is well formed and nested
+// under the right parent, and that names are usable as folder names.
+func validate(t *tree.Tree) error {
+	for _, area := range t.Areas {
+		m := jd.AreaFolderPattern.FindStringSubmatch(area.Number)
+		if m == nil || m[3] != "" || m[1][1] != '0' || m[2][0] != m[1][0] || m[2][1] != '9' {
+			return fmt.Errorf("invalid area number %q", area.Number)
+		}
+		if err := validateName(area.Name); err != nil {
+			return err
+		}
+
+		for _, category := range area.Categories {
+			m := jd.CategoryFolderPattern.FindStringSubmatch(category.Number)
+			if m == nil || m[2] != "" {
+				return fmt.Errorf("invalid category number %q", category.Number)
+			}
+			if category.Number[0] != area.Number[0] {
+				return fmt.Errorf("category %s does not belong in area %s", category.Number, area.Number)
+			}
+			if err := validateName(category.Name); err != nil {
+				return err
+			}
+
+			for _, id := range category.IDs {
+				m := jd.IDFolderPattern.FindStringSubmatch(id.Number)
+				if m == nil || m[3] != "" {
+					return fmt.Errorf("invalid ID number %q", id.Number)
+				}
+				if m[1] != category.Number {
+					return fmt.Errorf("ID %s does not belong in category %s", id.Number, category.Number)
+				}
+				if err := validateName(id.Name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validateName rejects names that cannot be used as a single folder name.
+func validateName(name string) error {
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("invalid folder name %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mahyarmirrashed/jdd/internal/tree"
+)
+
+// oneID returns an index with one area, category and ID, the ID named name.
+func oneID(name string) *tree.Tree {
+	return &tree.Tree{Areas: []*tree.Area{{
+		Number: "10-19", Name: "Life",
+		Categories: []*tree.Category{{
+			Number: "15", Name: "Travel",
+			IDs: []*tree.ID{{Number: "15.23", Name: name}},
+		}},
+	}}}
+}
+
+// mkdirs creates the folders under root.
+func mkdirs(t *testing.T, root string, dirs ...string) {
+	t.Helper()
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// exists reports whether the folder dir exists under root.
+func exists(root, dir string) bool {
+	_, err := os.Stat(filepath.Join(root, filepath.FromSlash(dir)))
+	return err == nil
+}
+
+func TestImport(t *testing.T) {
+	root := t.TempDir()
+
+	changes, err := Import(root, oneID("Japan"), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 3 {
+		t.Fatalf("got %d changes, want 3: %+v", len(changes), changes)
+	}
+	if !exists(root, "10-19 Life/15 Travel/15.23 Japan") {
+		t.Fatal("ID folder not created")
+	}
+
+	changes, err = Import(root, oneID("Japan"), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("second import made changes: %+v", changes)
+	}
+}
+
+func TestImportRename(t *testing.T) {
+	tests := []struct {
+		name   string // Of the ID in the index
+		action string // Expected for the ID, or "" for none
+		want   string // ID folder afterwards
+	}{
+		{"Japan", "", "15.23 Japan"},
+		{"Korea", ActionRename, "15.23 Korea"},
+		{"", "", "15.23 Japan"}, // No name in the index keeps the folder's
+	}
+	for _, tt := range tests {
+		t.Run(tt.want+"/"+tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			mkdirs(t, root, "10-19 Life/15 Travel/15.23 Japan")
+
+			changes, err := Import(root, oneID(tt.name), ImportOptions{Rename: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			action := ""
+			if len(changes) > 0 {
+				action = changes[0].Action
+			}
+			if action != tt.action || len(changes) > 1 {
+				t.Fatalf("got changes %+v, want %q", changes, tt.action)
+			}
+			if !exists(root, "10-19 Life/15 Travel/"+tt.want) {
+				t.Fatalf("%s missing", tt.want)
+			}
+		})
+	}
+}
+
+func TestImportProtected(t *testing.T) {
+	root := t.TempDir()
+	mkdirs(t, root, "10-19 Life/15 Travel/15.23 Japan")
+	if err := os.WriteFile(filepath.Join(root, "10-19 Life", "15 Travel", "15.23 Japan", ".jdkeep"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := Import(root, oneID("Korea"), ImportOptions{Rename: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Action != ActionProtected {
+		t.Fatalf("got changes %+v, want one %s", changes, ActionProtected)
+	}
+	if !exists(root, "10-19 Life/15 Travel/15.23 Japan") {
+		t.Fatal("protected folder renamed")
+	}
+}
+
+func TestImportMisplaced(t *testing.T) {
+	root := t.TempDir()
+	mkdirs(t, root, "10-19 Life/15 Travel", "10-19 Life/16 Money/15.23 Japan")
+
+	changes, err := Import(root, oneID("Japan"), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Action != ActionMisplaced {
+		t.Fatalf("got changes %+v, want one %s", changes, ActionMisplaced)
+	}
+	if changes[0].From != filepath.Join(root, "10-19 Life", "16 Money", "15.23 Japan") {
+		t.Fatalf("reported %s", changes[0].From)
+	}
+	if exists(root, "10-19 Life/15 Travel/15.23 Japan") {
+		t.Fatal("duplicate ID folder created")
+	}
+}
+
+func TestImportMisplacedCategory(t *testing.T) {
+	root := t.TempDir()
+	mkdirs(t, root, "20-29 Work/15 Travel/15.23 Japan")
+
+	changes, err := Import(root, oneID("Japan"), ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The area is created, the category reported, and its ID found inside it
+	if len(changes) != 2 || changes[0].Action != ActionCreate || changes[1].Action != ActionMisplaced {
+		t.Fatalf("got changes %+v", changes)
+	}
+	if exists(root, "10-19 Life/15 Travel") {
+		t.Fatal("duplicate category folder created")
+	}
+}
@@ -0,0 +1,92 @@
+package index
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mahyarmirrashed/jdd/internal/tree"
+)
+
+// Formats lists the supported index formats.
+var Formats = []string{"markdown", "json", "csv", "yaml"}
+
+// Write renders the tree to w in the given format.
+func Write(w io.Writer, t *tree.Tree, format string) error {
+	switch strings.ToLower(format) {
+	case "markdown", "md":
+		_, err := io.WriteString(w, Markdown(t))
+		return err
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(t)
+	case "csv":
+		return writeCSV(w, t)
+	case "yaml", "yml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(t); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("unknown index format %q (expected one of %s)", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Markdown renders the tree as a markdown document with one heading per area
+// and category, and one list item per ID.
+func Markdown(t *tree.Tree) string {
+	var b bytes.Buffer
+	b.WriteString("# Index\n")
+
+	for _, area := range t.Areas {
+		fmt.Fprintf(&b, "\n## %s\n", area.Label())
+
+		for _, category := range area.Categories {
+			fmt.Fprintf(&b, "\n### %s\n", category.Label())
+
+			if len(category.IDs) > 0 {
+				b.WriteString("\n")
+			}
+			for _, id := range category.IDs {
+				fmt.Fprintf(&b, "- %s\n", id.Label())
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// writeCSV writes one row per area, category and ID.
+func writeCSV(w io.Writer, t *tree.Tree) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"level", "number", "name", "path"}); err != nil {
+		return err
+	}
+
+	for _, area := range t.Areas {
+		if err := cw.Write([]string{"area", area.Number, area.Name, area.Path}); err != nil {
+			return err
+		}
+		for _, category := range area.Categories {
+			if err := cw.Write([]string{"category", category.Number, category.Name, category.Path}); err != nil {
+				return err
+			}
+			for _, id := range category.IDs {
+				if err := cw.Write([]string{"id", id.Number, id.Name, id.Path}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,180 @@
+package jd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/lock"
+)
+
+// LockFilename is the lock file taken in a category folder while allocating an ID.
+const LockFilename = ".jdd.lock"
+
+// lockTimeout is how long Allocate waits for another allocation in the same category.
+const lockTimeout = 10 * time.Second
+
+// rangePattern matches a reserved ID range like "00-09" or a single ID like "99".
+var rangePattern = regexp.MustCompile(`^(\d{2})(?:-(\d{2}))?$`)
+
+// Range is an inclusive range of ID numbers within a category, e.g. 0-9 for x.00-x.09.
+type Range struct {
+	From int
+	To   int
+}
+
+// ParseRanges parses reserved ID ranges like "00-09" or "99".
+func ParseRanges(specs []string) ([]Range, error) {
+	var ranges []Range
+	for _, spec := range specs {
+		m := rangePattern.FindStringSubmatch(strings.TrimSpace(spec))
+		if m == nil {
+			return nil, fmt.Errorf("invalid ID range %q (expected e.g. 00-09)", spec)
+		}
+
+		from, _ := strconv.Atoi(m[1])
+		to := from
+		if m[2] != "" {
+			to, _ = strconv.Atoi(m[2])
+		}
+		if to < from {
+			return nil, fmt.Errorf("invalid ID range %q: end before start", spec)
+		}
+
+		ranges = append(ranges, Range{From: from, To: to})
+	}
+	return ranges, nil
+}
+
+// contains reports whether n falls in any of the ranges.
+func contains(ranges []Range, n int) bool {
+	for _, r := range ranges {
+		if n >= r.From && n <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+// NextID returns the next free ID in category under root without creating
+// anything. IDs are allocated after the highest one in use; once x.99 is
+// taken, the lowest gap is used instead. x.00 and reserved IDs are never
+// allocated.
+func NextID(root, category string, reserved []Range) (*JohnnyDecimal, error) {
+	jdObj, err := parseCategory(category)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[int]bool)
+
+	categoryPath, err := jdObj.findCategoryFolder(root)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		entries, err := os.ReadDir(categoryPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			m := JohnnyDecimalFilePattern.FindStringSubmatch(entry.Name())
+			if entry.IsDir() && m != nil && m[1] == category {
+				n, _ := strconv.Atoi(m[2])
+				used[n] = true
+			}
+		}
+	}
+
+	free := func(n int) bool {
+		return !used[n] && !contains(reserved, n)
+	}
+
+	highest := 0
+	for n := range used {
+		if n > highest && !contains(reserved, n) {
+			highest = n
+		}
+	}
+	for n := highest + 1; n <= 99; n++ {
+		if free(n) {
+			return withID(jdObj, n), nil
+		}
+	}
+	for n := 1; n <= highest; n++ {
+		if free(n) {
+			return withID(jdObj, n), nil
+		}
+	}
+
+	return nil, fmt.Errorf("category %s has no free IDs left", category)
+}
+
+// Allocate creates a folder for the next free ID in category under root,
+// named after the ID and name, e.g. "15.24 Japan trip". It holds a lock file
+// in the category folder while doing so, so that concurrent allocations, even
+// from other machines sharing root, never hand out the same ID.
+func Allocate(root, category, name string, reserved []Range) (*JohnnyDecimal, string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		return nil, "", fmt.Errorf("invalid name %q", name)
+	}
+
+	jdObj, err := parseCategory(category)
+	if err != nil {
+		return nil, "", err
+	}
+
+	categoryPath, err := jdObj.EnsureCategoryFolder(root)
+	if err != nil {
+		return nil, "", err
+	}
+
+	l, err := lock.Acquire(filepath.Join(categoryPath, LockFilename), lockTimeout)
+	if err != nil {
+		return nil, "", err
+	}
+	defer l.Release()
+
+	next, err := NextID(root, category, reserved)
+	if err != nil {
+		return nil, "", err
+	}
+
+	path := filepath.Join(categoryPath, strings.TrimSpace(next.ID+" "+name))
+	if err := os.Mkdir(path, 0755); err != nil {
+		return nil, "", err
+	}
+
+	return next, path, nil
+}
+
+// parseCategory returns the JohnnyDecimal object for ID x.00 of a category like "15".
+func parseCategory(category string) (*JohnnyDecimal, error) {
+	if m := CategoryFolderPattern.FindStringSubmatch(category); m == nil || m[2] != "" {
+		return nil, fmt.Errorf("invalid category %q (expected two digits, e.g. 15)", category)
+	}
+	return Parse(category + ".00")
+}
+
+// findCategoryFolder looks up the existing category folder under root.
+func (jd *JohnnyDecimal) findCategoryFolder(root string) (string, error) {
+	areaPath, err := FindPrefixedFolder(root, jd.Area)
+	if err != nil {
+		return "", err
+	}
+	return FindPrefixedFolder(areaPath, jd.Category)
+}
+
+// withID returns a copy of jdObj with the ID number within its category set to n.
+func withID(jdObj *JohnnyDecimal, n int) *JohnnyDecimal {
+	next := *jdObj
+	next.ID = fmt.Sprintf("%s.%02d", jdObj.Category, n)
+	next.SubID = ""
+	next.SubIDs = nil
+	return &next
+}
@@ -0,0 +1,223 @@
+package jd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// folderCache remembers the subfolders of directories already listed, so that
+// filing many files does not list the same area and category over and over.
+// It is off until EnableFolderCache is called, and then only covers
+// directories under the given roots, whose changes the caller must report
+// through FolderCreated and FolderRemoved.
+//
+// Folders are kept under their parent directory, so that a removed folder and
+// everything cached below it are found without looking at the rest. Every
+// folder between a cached one and its root is kept too, listed or not.
+var folderCache struct {
+	sync.Mutex
+	roots []string
+	dirs  map[string]map[string]*cachedDir // Parent directory -> folder name -> folder
+}
+
+// cachedDir is a folder in the cache.
+type cachedDir struct {
+	names  []string // Sorted subfolder names; never modified in place
+	listed bool     // Whether names is known, rather than the folder only leading to those below
+}
+
+// EnableFolderCache starts caching the folder listings of directories under
+// roots. With no roots, the cache is disabled, as by DisableFolderCache.
+func EnableFolderCache(roots ...string) {
+	folderCache.Lock()
+	defer folderCache.Unlock()
+	folderCache.roots = nil
+	folderCache.dirs = nil
+	for _, root := range roots {
+		if abs, err := filepath.Abs(root); err == nil {
+			folderCache.roots = append(folderCache.roots, abs)
+		}
+	}
+	if len(folderCache.roots) > 0 {
+		folderCache.dirs = make(map[string]map[string]*cachedDir)
+	}
+}
+
+// DisableFolderCache stops caching and forgets every cached listing.
+func DisableFolderCache() {
+	EnableFolderCache()
+}
+
+// ResetFolderCache forgets every cached listing, for when the cache may have
+// missed a change.
+func ResetFolderCache() {
+	folderCache.Lock()
+	defer folderCache.Unlock()
+	if folderCache.dirs != nil {
+		folderCache.dirs = make(map[string]map[string]*cachedDir)
+	}
+}
+
+// FolderCreated reports that a folder appeared at path.
+func FolderCreated(path string) {
+	parent, name, ok := cacheKey(path)
+	if !ok {
+		return
+	}
+
+	folderCache.Lock()
+	defer folderCache.Unlock()
+	d := cachedListing(parent)
+	if d == nil || !d.listed {
+		return
+	}
+	if i, found := slices.BinarySearch(d.names, name); !found {
+		d.names = slices.Insert(slices.Clone(d.names), i, name)
+	}
+}
+
+// FolderRemoved reports that whatever was at path was removed or renamed away.
+// Paths that were not folders are ignored.
+func FolderRemoved(path string) {
+	parent, name, ok := cacheKey(path)
+	if !ok {
+		return
+	}
+
+	folderCache.Lock()
+	defer folderCache.Unlock()
+	if d := cachedListing(parent); d != nil && d.listed {
+		if i, found := slices.BinarySearch(d.names, name); found {
+			d.names = slices.Delete(slices.Clone(d.names), i, i+1)
+		}
+	}
+
+	// The folder's own listing and those below it are gone with it
+	if siblings := folderCache.dirs[parent]; siblings != nil {
+		delete(siblings, name)
+		if len(siblings) == 0 {
+			delete(folderCache.dirs, parent)
+		}
+	}
+	forgetBelow(filepath.Join(parent, name))
+}
+
+// subfolders returns the names of the folders in dir, sorted. With fresh set,
+// or if dir is not cached, they are read from disk.
+func subfolders(dir string, fresh bool) ([]string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	cacheable := cached(abs)
+
+	if cacheable && !fresh {
+		folderCache.Lock()
+		var names []string
+		d := cachedListing(abs)
+		listed := d != nil && d.listed
+		if listed {
+			names = d.names
+		}
+		folderCache.Unlock()
+		if listed {
+			return names, nil
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	if cacheable {
+		folderCache.Lock()
+		if folderCache.dirs != nil {
+			d := cacheDir(abs)
+			d.names, d.listed = names, true
+		}
+		folderCache.Unlock()
+	}
+	return names, nil
+}
+
+// cachedListing returns the cached folder at the absolute path dir, or nil.
+// The cache must be locked.
+func cachedListing(dir string) *cachedDir {
+	return folderCache.dirs[filepath.Dir(dir)][filepath.Base(dir)]
+}
+
+// cacheDir returns the cached folder at the absolute path dir, under one of
+// the roots, adding it and the folders between it and its root if need be.
+// The cache must be locked.
+func cacheDir(dir string) *cachedDir {
+	var found *cachedDir
+	for path := dir; ; {
+		parent, name := filepath.Dir(path), filepath.Base(path)
+		siblings := folderCache.dirs[parent]
+		if siblings == nil {
+			siblings = make(map[string]*cachedDir)
+			folderCache.dirs[parent] = siblings
+		}
+		d, ok := siblings[name]
+		if !ok {
+			d = &cachedDir{}
+			siblings[name] = d
+		}
+		if found == nil {
+			found = d
+		}
+		if ok || parent == path || slices.Contains(folderCache.roots, path) {
+			return found
+		}
+		path = parent
+	}
+}
+
+// forgetBelow drops every cached folder below the absolute path dir. The
+// cache must be locked.
+func forgetBelow(dir string) {
+	children := folderCache.dirs[dir]
+	delete(folderCache.dirs, dir)
+	for name := range children {
+		forgetBelow(filepath.Join(dir, name))
+	}
+}
+
+// inCache reports whether the listing of dir may be cached.
+func inCache(dir string) bool {
+	abs, err := filepath.Abs(dir)
+	return err == nil && cached(abs)
+}
+
+// cached reports whether the listing of the absolute directory dir is cached.
+func cached(dir string) bool {
+	folderCache.Lock()
+	defer folderCache.Unlock()
+	for _, root := range folderCache.roots {
+		if dir == root || strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheKey returns the absolute parent directory and name of path, and
+// whether the parent's listing may be cached.
+func cacheKey(path string) (string, string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", false
+	}
+	parent := filepath.Dir(abs)
+	return parent, filepath.Base(abs), cached(parent)
+}
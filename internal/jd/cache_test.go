@@ -0,0 +1,96 @@
+package jd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// mkdirs creates the folders under root.
+func mkdirs(t *testing.T, root string, dirs ...string) {
+	t.Helper()
+	for _, dir := range dirs {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// listing returns the cached listing of dir, and whether there is one.
+func listing(t *testing.T, dir string) ([]string, bool) {
+	t.Helper()
+	folderCache.Lock()
+	defer folderCache.Unlock()
+	d := cachedListing(dir)
+	if d == nil || !d.listed {
+		return nil, false
+	}
+	return d.names, true
+}
+
+func TestFolderCache(t *testing.T) {
+	root := t.TempDir()
+	EnableFolderCache(root)
+	t.Cleanup(DisableFolderCache)
+	mkdirs(t, root, "10-19/15 Travel/15.23 Japan", "20-29")
+
+	area := filepath.Join(root, "10-19")
+	category := filepath.Join(area, "15 Travel")
+	id := filepath.Join(category, "15.23 Japan")
+	// The ID is listed before the folders above it
+	for _, dir := range []string{id, root, category} {
+		if _, err := subfolders(dir, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names, ok := listing(t, root); !ok || !slices.Equal(names, []string{"10-19", "20-29"}) {
+		t.Fatalf("got %v, %v for the root", names, ok)
+	}
+	if _, ok := listing(t, area); ok {
+		t.Fatal("the area was never listed but is cached")
+	}
+
+	// Created folders are added without reading the disk
+	mkdirs(t, root, "10-19/15 Travel/15.24 Korea")
+	FolderCreated(filepath.Join(category, "15.24 Korea"))
+	if names, _ := listing(t, category); !slices.Equal(names, []string{"15.23 Japan", "15.24 Korea"}) {
+		t.Fatalf("got %v after creating a folder", names)
+	}
+
+	// Removing the area forgets every listing below it, listed or not
+	if err := os.RemoveAll(area); err != nil {
+		t.Fatal(err)
+	}
+	FolderRemoved(area)
+	if names, _ := listing(t, root); !slices.Equal(names, []string{"20-29"}) {
+		t.Fatalf("got %v after removing the area", names)
+	}
+	for _, dir := range []string{category, id} {
+		if _, ok := listing(t, dir); ok {
+			t.Errorf("%s is still cached after the area was removed", dir)
+		}
+	}
+	folderCache.Lock()
+	if len(folderCache.dirs[area]) != 0 || len(folderCache.dirs[category]) != 0 {
+		t.Errorf("folders below the area are still kept: %v", folderCache.dirs)
+	}
+	folderCache.Unlock()
+
+	// Folders outside the roots are never cached
+	outside := t.TempDir()
+	if _, err := subfolders(outside, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := listing(t, outside); ok {
+		t.Error("a folder outside the roots was cached")
+	}
+
+	DisableFolderCache()
+	if _, err := subfolders(root, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := listing(t, root); ok {
+		t.Error("listed with the cache disabled")
+	}
+}
@@ -1,38 +1,71 @@
 package jd
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
 // JohnnyDecimalFilePattern matches a Johnny Decimal filename prefix like "15.23" or "15.23+JEM".
 var JohnnyDecimalFilePattern = regexp.MustCompile(`^(\d{2})\.(\d{2})(\+\S+)?`)
 
-// JohnnyDecimal represents a parsed Johnny Decimal ID with optional sub-ID.
+// AreaFolderPattern matches an area folder name like "10-19" or "10-19 Life admin".
+var AreaFolderPattern = regexp.MustCompile(`^(\d{2})-(\d{2})(?:\s+(.*))?$`)
+
+// CategoryFolderPattern matches a category folder name like "15" or "15 Travel".
+var CategoryFolderPattern = regexp.MustCompile(`^(\d{2})(?:\s+(.*))?$`)
+
+// IDFolderPattern matches an ID folder name like "15.23" or "15.23 Japan".
+var IDFolderPattern = regexp.MustCompile(`^(\d{2})\.(\d{2})(?:\s+(.*))?$`)
+
+// SystemPrefixPattern matches the system prefix of a multi-system ID like "P01.15.23".
+var SystemPrefixPattern = regexp.MustCompile(`^([A-Z]\d{2})\.`)
+
+// SystemPattern matches a system code like "P01" or "W02".
+var SystemPattern = regexp.MustCompile(`^[A-Z]\d{2}$`)
+
+// JohnnyDecimal represents a parsed Johnny Decimal ID with optional system and sub-ID.
 type JohnnyDecimal struct {
-	Area     string // Area range, e.g. "10-19"
-	Category string // Category number, e.g. "15"
-	ID       string // Full ID, e.g. "15.23"
-	SubID    string // Optional sub-ID, e.g. "+JEM" or "+0001"
+	System   string   // Optional system code, e.g. "P01"
+	Area     string   // Area range, e.g. "10-19"
+	Category string   // Category number, e.g. "15"
+	ID       string   // Full ID, e.g. "15.23"
+	SubID    string   // Optional sub-ID, e.g. "+JEM", "+0001" or "+JEM+2024"
+	SubIDs   []string // Levels of the sub-ID, one folder each, e.g. ["JEM", "2024"]
+}
+
+// SubIDLayout controls how the levels of a sub-ID map to folders.
+type SubIDLayout struct {
+	Depth   int  // Most sub-ID folder levels; deeper levels are filed in the deepest one. 0 means no limit
+	Flatten bool // Use a single folder named with the whole sub-ID, e.g. "15.23+JEM+2024"
+}
+
+// ApplyLayout regroups the sub-ID levels of jd according to layout.
+func (jd *JohnnyDecimal) ApplyLayout(layout SubIDLayout) {
+	if layout.Flatten && len(jd.SubIDs) > 1 {
+		jd.SubIDs = []string{strings.Join(jd.SubIDs, "+")}
+	}
+	if layout.Depth > 0 && len(jd.SubIDs) > layout.Depth {
+		jd.SubIDs = jd.SubIDs[:layout.Depth]
+	}
 }
 
 // EnsureFolders ensures the folder structure for the JohnnyDecimal object exists under root.
-// It creates folders for Area, Category, ID, and optionally the SubID (extension).
-// Returns the final folder path.
+// It creates folders for Area, Category, ID, and one nested folder per SubID level
+// (extension), e.g. "15.23+JEM/15.23+JEM+2024". Returns the final folder path.
 func (jd *JohnnyDecimal) EnsureFolders(root string) (string, error) {
-	// Ensure Area folder
-	areaPath, err := findOrCreatePrefixedFolder(root, jd.Area)
+	// Ensure Area and Category folders
+	categoryPath, err := jd.EnsureCategoryFolder(root)
 	if err != nil {
-		return "", fmt.Errorf("could not ensure area folder: %w", err)
-	}
-	// Ensure Category folder
-	categoryPath, err := findOrCreatePrefixedFolder(areaPath, jd.Category)
-	if err != nil {
-		return "", fmt.Errorf("could not ensure category folder: %w", err)
+		return "", err
 	}
 	// Ensure ID folder
 	idPath, err := findOrCreatePrefixedFolder(categoryPath, jd.ID)
@@ -42,9 +75,9 @@ func (jd *JohnnyDecimal) EnsureFolders(root string) (string, error) {
 
 	finalPath := idPath
 
-	// Ensure SubID (extension) folder if present
-	if jd.SubID != "" {
-		extPath, err := findOrCreatePrefixedFolder(idPath, jd.ID+jd.SubID)
+	// Ensure SubID (extension) folders if present
+	for _, prefix := range jd.SubIDFolders() {
+		extPath, err := findOrCreatePrefixedFolder(finalPath, prefix)
 		if err != nil {
 			return "", fmt.Errorf("could not ensure extension folder: %w", err)
 		}
@@ -55,14 +88,73 @@ func (jd *JohnnyDecimal) EnsureFolders(root string) (string, error) {
 	return finalPath, nil
 }
 
+// EnsureAreaFolder ensures the Area folder exists under root and returns its path.
+func (jd *JohnnyDecimal) EnsureAreaFolder(root string) (string, error) {
+	areaPath, err := findOrCreatePrefixedFolder(root, jd.Area)
+	if err != nil {
+		return "", fmt.Errorf("could not ensure area folder: %w", err)
+	}
+	return areaPath, nil
+}
+
+// EnsureCategoryFolder ensures the Area and Category folders exist under root and
+// returns the Category folder path.
+func (jd *JohnnyDecimal) EnsureCategoryFolder(root string) (string, error) {
+	areaPath, err := jd.EnsureAreaFolder(root)
+	if err != nil {
+		return "", err
+	}
+	categoryPath, err := findOrCreatePrefixedFolder(areaPath, jd.Category)
+	if err != nil {
+		return "", fmt.Errorf("could not ensure category folder: %w", err)
+	}
+	return categoryPath, nil
+}
+
+// FindFolders looks up the existing folder for the JohnnyDecimal object under root
+// without creating anything. It resolves folders the same way as EnsureFolders.
+// Returns an error wrapping os.ErrNotExist if any folder along the way is missing.
+func (jd *JohnnyDecimal) FindFolders(root string) (string, error) {
+	path := root
+	for _, prefix := range jd.FolderPrefixes() {
+		next, err := FindPrefixedFolder(path, prefix)
+		if err != nil {
+			return "", err
+		}
+		path = next
+	}
+	return path, nil
+}
+
+// FolderPrefixes returns the folder prefixes from area down to the deepest SubID level, if any.
+func (jd *JohnnyDecimal) FolderPrefixes() []string {
+	return append(jd.FolderPath(), jd.SubIDFolders()...)
+}
+
+// SubIDFolders returns the folder prefix of each SubID level, e.g.
+// "15.23+JEM" and "15.23+JEM+2024".
+func (jd *JohnnyDecimal) SubIDFolders() []string {
+	prefixes := make([]string, len(jd.SubIDs))
+	for i := range jd.SubIDs {
+		prefixes[i] = jd.ID + "+" + strings.Join(jd.SubIDs[:i+1], "+")
+	}
+	return prefixes
+}
+
 // FolderPath returns the folder path segments for this JohnnyDecimal object (Area, Category, ID).
 func (jd *JohnnyDecimal) FolderPath() []string {
 	return []string{jd.Area, jd.Category, jd.ID}
 }
 
-// Parse parses a filename prefix like "15.23" or "15.23+JEM" and returns a JohnnyDecimal object.
-// Returns an error if the filename does not match the Johnny Decimal pattern.
+// Parse parses a filename prefix like "15.23", "15.23+JEM" or "P01.15.23" and returns a
+// JohnnyDecimal object. Returns an error if the filename does not match the Johnny Decimal pattern.
 func Parse(filename string) (*JohnnyDecimal, error) {
+	system := ""
+	if m := SystemPrefixPattern.FindStringSubmatch(filename); m != nil {
+		system = m[1]
+		filename = filename[len(m[0]):]
+	}
+
 	matches := JohnnyDecimalFilePattern.FindStringSubmatch(filename)
 	if len(matches) < 3 {
 		return nil, fmt.Errorf("filename does not match Johnny Decimal pattern")
@@ -72,8 +164,14 @@ func Parse(filename string) (*JohnnyDecimal, error) {
 	id := fmt.Sprintf("%s.%s", matches[1], matches[2])
 
 	subid := ""
+	var subids []string
 	if len(matches) >= 4 && matches[3] != "" {
 		subid = matches[3] // includes the "+"
+		for _, level := range strings.Split(subid[1:], "+") {
+			if level != "" {
+				subids = append(subids, level)
+			}
+		}
 	}
 
 	firstDigit, err := strconv.Atoi(string(category[0]))
@@ -86,10 +184,12 @@ func Parse(filename string) (*JohnnyDecimal, error) {
 	area := fmt.Sprintf("%02d-%02d", areaStart, areaEnd)
 
 	return &JohnnyDecimal{
+		System:   system,
 		Area:     area,
 		Category: category,
 		ID:       id,
 		SubID:    subid,
+		SubIDs:   subids,
 	}, nil
 }
 
@@ -97,6 +197,10 @@ func Parse(filename string) (*JohnnyDecimal, error) {
 func (jd *JohnnyDecimal) String() string {
 	str := fmt.Sprintf("Area: %s, Category: %s, ID: %s", jd.Area, jd.Category, jd.ID)
 
+	if jd.System != "" {
+		str = fmt.Sprintf("System: %s, %s", jd.System, str)
+	}
+
 	if jd.SubID != "" {
 		str = fmt.Sprintf("%s, Sub-ID: %s", str, jd.SubID)
 	}
@@ -104,23 +208,98 @@ func (jd *JohnnyDecimal) String() string {
 	return str
 }
 
-// findOrCreatePrefixedFolder looks for a folder in parentDir starting with prefix.
+// EnsurePrefixedFolders ensures a chain of folders exists under root, one per
+// prefix, each found by its prefix or created with it as its name. Returns the
+// innermost folder path.
+func EnsurePrefixedFolders(root string, prefixes []string) (string, error) {
+	path := root
+	for _, prefix := range prefixes {
+		next, err := findOrCreatePrefixedFolder(path, prefix)
+		if err != nil {
+			return "", fmt.Errorf("could not ensure folder %s: %w", prefix, err)
+		}
+		path = next
+	}
+	return path, nil
+}
+
+// dirLocks serialise finding or creating folders by parent directory, so that
+// goroutines filing into the same area or category agree on one folder for
+// each prefix. Directories share a fixed set of locks, picked by hash, rather
+// than keeping one for every directory ever filed into.
+var dirLocks [64]sync.Mutex
+
+// dirLock returns the lock for the parent directory dir.
+func dirLock(dir string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(filepath.Clean(dir)))
+	return &dirLocks[h.Sum32()%uint32(len(dirLocks))]
+}
+
+// findOrCreatePrefixedFolder looks for the folder for prefix in parentDir.
 // If found, returns its path. Otherwise, creates the folder and returns its path.
+// It is safe to call from several goroutines at once.
 func findOrCreatePrefixedFolder(parentDir, prefix string) (string, error) {
-	entries, err := os.ReadDir(parentDir)
-	if err != nil {
-		return "", err
+	mu := dirLock(parentDir)
+	mu.Lock()
+	defer mu.Unlock()
+
+	path, err := findPrefixedFolder(parentDir, prefix, false)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return path, err
 	}
-	for _, entry := range entries {
-		if entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) {
-			return filepath.Join(parentDir, entry.Name()), nil
+	// A cached listing may have missed a folder created a moment ago; look
+	// again before creating one that would duplicate it
+	if inCache(parentDir) {
+		path, err = findPrefixedFolder(parentDir, prefix, true)
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return path, err
 		}
 	}
 
 	// Not found, create it
 	fullPath := filepath.Join(parentDir, prefix)
-	if err := os.Mkdir(fullPath, 0755); err != nil && !os.IsExist(err) {
-		return "", err
+	if err := os.Mkdir(fullPath, 0755); err != nil {
+		if !os.IsExist(err) {
+			return "", err
+		}
+		// Created by another process in the meantime, perhaps with a longer name
+		return findPrefixedFolder(parentDir, prefix, true)
 	}
+	FolderCreated(fullPath)
 	return fullPath, nil
 }
+
+// HasFolderPrefix reports whether a folder named name is the folder for
+// prefix: the name is the prefix, perhaps followed by a space and a title.
+// "Website" is not the folder for "Web", nor "5120" for "512", and
+// "15.23+JEM+2024", a deeper sub-ID, is not the folder for "15.23+JEM".
+func HasFolderPrefix(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return len(name) == len(prefix) || unicode.IsSpace(next)
+}
+
+// FindPrefixedFolder looks for the folder for prefix in parentDir (see
+// HasFolderPrefix), without creating it. Returns an error wrapping
+// os.ErrNotExist if there is none.
+func FindPrefixedFolder(parentDir, prefix string) (string, error) {
+	return findPrefixedFolder(parentDir, prefix, false)
+}
+
+// findPrefixedFolder is FindPrefixedFolder, reading parentDir from disk
+// rather than the folder cache if fresh is set.
+func findPrefixedFolder(parentDir, prefix string, fresh bool) (string, error) {
+	names, err := subfolders(parentDir, fresh)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range names {
+		if HasFolderPrefix(name, prefix) {
+			return filepath.Join(parentDir, name), nil
+		}
+	}
+	return "", fmt.Errorf("no folder for %q in %s: %w", prefix, parentDir, os.ErrNotExist)
+}
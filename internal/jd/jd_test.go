@@ -0,0 +1,81 @@
+package jd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mahyarmirrashed/jdd/internal/testutil"
+)
+
+// TestEnsurePrefixedFoldersConcurrent files many IDs of one category at once
+// and checks that the area and category folders are each created only once.
+func TestEnsurePrefixedFoldersConcurrent(t *testing.T) {
+	for _, cache := range []bool{false, true} {
+		t.Run(fmt.Sprintf("cache=%v", cache), func(t *testing.T) {
+			root := t.TempDir()
+			if cache {
+				EnableFolderCache(root)
+			} else {
+				DisableFolderCache()
+			}
+			t.Cleanup(DisableFolderCache)
+
+			const goroutines, ids = 64, 8
+			var wg sync.WaitGroup
+			errs := make(chan error, goroutines)
+			for i := range goroutines {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					id := fmt.Sprintf("15.%02d", i%ids)
+					path, err := EnsurePrefixedFolders(root, []string{"10-19", "15", id})
+					if err == nil && path != filepath.Join(root, "10-19", "15", id) {
+						err = fmt.Errorf("got folder %s for %s", path, id)
+					}
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			testutil.AssertEntries(t, root, 1)
+			testutil.AssertEntries(t, filepath.Join(root, "10-19"), 1)
+			testutil.AssertEntries(t, filepath.Join(root, "10-19", "15"), ids)
+		})
+	}
+}
+
+func TestHasFolderPrefix(t *testing.T) {
+	tests := []struct {
+		name, prefix string
+		want         bool
+	}{
+		{"15.23", "15.23", true},
+		{"15.23 Japan", "15.23", true},
+		{"15.23\tJapan", "15.23", true},
+		{"15.234", "15.23", false},
+		{"15.23Japan", "15.23", false},
+		{"15.23+JEM", "15.23", false},
+		{"15.23+JEM Notes", "15.23+JEM", true},
+		{"15.23+JEM+2024", "15.23+JEM", false},
+		{"Web", "Web", true},
+		{"Web design", "Web", true},
+		{"Website", "Web", false},
+		{"Cards", "Car", false},
+		{"5120", "512", false},
+		{"512 Algebra", "512", true},
+		{"51", "512", false},
+	}
+	for _, tt := range tests {
+		if got := HasFolderPrefix(tt.name, tt.prefix); got != tt.want {
+			t.Errorf("HasFolderPrefix(%q, %q) = %v, want %v", tt.name, tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package jd
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Named groups a filename pattern uses to capture the parts of an ID.
+const (
+	GroupSystem   = "system"   // Optional system code, e.g. "P01"
+	GroupCategory = "category" // Category number, e.g. "15"
+	GroupID       = "id"       // ID number within the category, e.g. "23"
+	GroupSubID    = "subid"    // Optional sub-ID including the "+", e.g. "+JEM"
+)
+
+// DefaultPreset is the preset used when no patterns are configured.
+const DefaultPreset = "prefix"
+
+// Presets are the built-in filename patterns, by name.
+var Presets = map[string]string{
+	// "15.23 Japan.pdf", "15.23+JEM notes.txt", "P01.15.23 Japan.pdf"
+	"prefix": `^(?:(?P<system>[A-Z]\d{2})\.)?(?P<category>\d{2})\.(?P<id>\d{2})(?P<subid>\+\S+)?`,
+	// "[15.23] Japan.pdf", "[15.23+JEM] notes.txt", "[P01.15.23] Japan.pdf"
+	"bracket": `^\[(?:(?P<system>[A-Z]\d{2})\.)?(?P<category>\d{2})\.(?P<id>\d{2})(?P<subid>\+[^\]\s]+)?\]`,
+	// "15-23 Japan.pdf", "15-23+JEM notes.txt", "P01-15-23 Japan.pdf"
+	"dash": `^(?:(?P<system>[A-Z]\d{2})-)?(?P<category>\d{2})-(?P<id>\d{2})(?P<subid>\+\S+)?(?:[\s._]|$)`,
+	// "Japan (15.23).pdf", "notes (15.23+JEM).txt", "Japan (P01.15.23).pdf"
+	"paren-suffix": `\((?:(?P<system>[A-Z]\d{2})\.)?(?P<category>\d{2})\.(?P<id>\d{2})(?P<subid>\+[^)\s]+)?\)(?:\.[^.]+)?$`,
+	// "Japan_15.23.pdf", "notes_15.23+JEM.txt", "Japan_P01.15.23.pdf"
+	"underscore-suffix": `_(?:(?P<system>[A-Z]\d{2})\.)?(?P<category>\d{2})\.(?P<id>\d{2})(?P<subid>\+[^.\s]+)?(?:\.[^.]+)?$`,
+}
+
+// placeholderNumbers stands in for the ID number of a placeholder ID, such as
+// "15.xx", asking for the next free ID in the category.
+const placeholderNumbers = `xx|XX|\?\?`
+
+// FilePattern is a named regular expression that finds an ID in a filename.
+type FilePattern struct {
+	Name   string // Preset name, or the expression itself for custom patterns
+	Regexp *regexp.Regexp
+
+	placeholder *regexp.Regexp // Regexp with a placeholder for the ID number
+}
+
+// PresetNames returns the names of the built-in presets, sorted.
+func PresetNames() []string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParsePatterns compiles filename patterns from config. Each spec is either a
+// preset name or a regular expression with "category" and "id" named groups,
+// and optionally "system" and "subid" (with or without its leading "+").
+// With no specs, the default preset is used.
+func ParsePatterns(specs []string) ([]*FilePattern, error) {
+	if len(specs) == 0 {
+		specs = []string{DefaultPreset}
+	}
+
+	patterns := make([]*FilePattern, 0, len(specs))
+	for _, spec := range specs {
+		expr, ok := Presets[spec]
+		if !ok {
+			expr = spec
+		}
+
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filename pattern %q: %w", spec, err)
+		}
+		for _, group := range []string{GroupCategory, GroupID} {
+			if re.SubexpIndex(group) < 0 {
+				return nil, fmt.Errorf("filename pattern %q has no %q group", spec, group)
+			}
+		}
+		placeholder, err := placeholderRegexp(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filename pattern %q: %w", spec, err)
+		}
+		patterns = append(patterns, &FilePattern{Name: spec, Regexp: re, placeholder: placeholder})
+	}
+	return patterns, nil
+}
+
+// placeholderRegexp compiles expr with its "id" group matching a placeholder
+// instead of a number, so that placeholders are found wherever the pattern
+// finds IDs.
+func placeholderRegexp(expr string) (*regexp.Regexp, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	numbers, err := syntax.Parse(placeholderNumbers, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+
+	var replace func(re *syntax.Regexp)
+	replace = func(re *syntax.Regexp) {
+		if re.Op == syntax.OpCapture && re.Name == GroupID {
+			re.Sub = []*syntax.Regexp{numbers}
+			return
+		}
+		for _, sub := range re.Sub {
+			replace(sub)
+		}
+	}
+	replace(re)
+	return regexp.Compile(re.String())
+}
+
+// Match returns the ID found in filename by the first pattern that matches,
+// and that pattern, or nil if none does.
+func Match(patterns []*FilePattern, filename string) (*JohnnyDecimal, *FilePattern) {
+	for _, p := range patterns {
+		if jdObj := p.Parse(filename); jdObj != nil {
+			return jdObj, p
+		}
+	}
+	return nil, nil
+}
+
+// Parse returns the ID the pattern finds in filename, or nil if it does not match.
+func (p *FilePattern) Parse(filename string) *JohnnyDecimal {
+	m := p.Regexp.FindStringSubmatch(filename)
+	if m == nil {
+		return nil
+	}
+
+	category := m[p.Regexp.SubexpIndex(GroupCategory)]
+	id := m[p.Regexp.SubexpIndex(GroupID)]
+	subid := ""
+	if i := p.Regexp.SubexpIndex(GroupSubID); i >= 0 && m[i] != "" {
+		subid = "+" + strings.TrimPrefix(m[i], "+")
+	}
+
+	system := ""
+	if i := p.Regexp.SubexpIndex(GroupSystem); i >= 0 && m[i] != "" {
+		system = m[i] + "."
+	}
+
+	jdObj, err := Parse(system + category + "." + id + subid)
+	if err != nil {
+		return nil
+	}
+	return jdObj
+}
+
+// Placeholder is a filename carrying a placeholder ID like "15.xx", asking
+// for the next free ID in its category.
+type Placeholder struct {
+	System   string // Optional system code, e.g. "P01"
+	Category string // Category number, e.g. "15"
+	Title    string // The rest of the filename without its extension, e.g. "Hotel booking"
+
+	filename   string
+	start, end int // Where the placeholder number is in filename
+}
+
+// Filename returns the filename with the placeholder replaced by the number
+// of id, e.g. "15.24 Hotel booking.pdf" for "15.xx Hotel booking.pdf" and "15.24".
+func (p *Placeholder) Filename(id string) string {
+	return p.filename[:p.start] + id[strings.LastIndex(id, ".")+1:] + p.filename[p.end:]
+}
+
+// MatchPlaceholder returns the placeholder ID found in filename by the first
+// pattern that finds one, or nil if none does. The placeholder must end
+// there: "15.xxl report.pdf" carries none.
+func MatchPlaceholder(patterns []*FilePattern, filename string) *Placeholder {
+	for _, p := range patterns {
+		m := p.placeholder.FindStringSubmatchIndex(filename)
+		if m == nil {
+			continue
+		}
+		group := func(name string) (int, int) {
+			i := p.placeholder.SubexpIndex(name)
+			if i < 0 {
+				return -1, -1
+			}
+			return m[2*i], m[2*i+1]
+		}
+
+		start, end := group(GroupID)
+		if next, _ := utf8.DecodeRuneInString(filename[end:]); unicode.IsLetter(next) || unicode.IsDigit(next) {
+			continue
+		}
+
+		ph := &Placeholder{filename: filename, start: start, end: end}
+		if s, e := group(GroupCategory); s >= 0 {
+			ph.Category = filename[s:e]
+		}
+		if s, e := group(GroupSystem); s >= 0 {
+			ph.System = filename[s:e]
+		}
+		// Suffix patterns take the extension in with the ID
+		stem := len(filename) - len(filepath.Ext(filename))
+		if m[1] > stem {
+			stem = m[1]
+		}
+		ph.Title = strings.Trim(filename[:m[0]]+filename[m[1]:stem], " _-")
+		return ph
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package jd
+
+import "testing"
+
+func TestMatchPlaceholder(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		filename string
+		want     *Placeholder // Nil if there is none
+		renamed  string       // Filename for 15.24
+	}{
+		{nil, "15.xx Hotel booking.pdf", &Placeholder{Category: "15", Title: "Hotel booking"}, "15.24 Hotel booking.pdf"},
+		{nil, "15.XX_Hotel.pdf", &Placeholder{Category: "15", Title: "Hotel"}, "15.24_Hotel.pdf"},
+		{nil, "15.?? Hotel.pdf", &Placeholder{Category: "15", Title: "Hotel"}, "15.24 Hotel.pdf"},
+		{nil, "15.xx.pdf", &Placeholder{Category: "15"}, "15.24.pdf"},
+		{nil, "P01.15.xx Hotel.pdf", &Placeholder{System: "P01", Category: "15", Title: "Hotel"}, "P01.15.24 Hotel.pdf"},
+		{nil, "15.xxl report.pdf", nil, ""},
+		{nil, "15.xx1 report.pdf", nil, ""},
+		{nil, "15.23 Hotel.pdf", nil, ""},
+		{nil, "Hotel (15.xx).pdf", nil, ""},
+		{[]string{"bracket"}, "[15.xx] Hotel.pdf", &Placeholder{Category: "15", Title: "Hotel"}, "[15.24] Hotel.pdf"},
+		{[]string{"dash"}, "15-xx Hotel.pdf", &Placeholder{Category: "15", Title: "Hotel"}, "15-24 Hotel.pdf"},
+		{[]string{"paren-suffix"}, "Report v1.2 (15.xx).pdf", &Placeholder{Category: "15", Title: "Report v1.2"}, "Report v1.2 (15.24).pdf"},
+		{[]string{"underscore-suffix"}, "Hotel_15.??.pdf", &Placeholder{Category: "15", Title: "Hotel"}, "Hotel_15.24.pdf"},
+		{[]string{`^ID(?P<category>\d{2})(?P<id>\d{2}) `}, "ID15xx Hotel.pdf", &Placeholder{Category: "15", Title: "Hotel"}, "ID1524 Hotel.pdf"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			patterns, err := ParsePatterns(tt.patterns)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := MatchPlaceholder(patterns, tt.filename)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("found placeholder %+v", *got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("no placeholder found")
+			}
+			if got.System != tt.want.System || got.Category != tt.want.Category || got.Title != tt.want.Title {
+				t.Errorf("got %q %q %q, want %q %q %q", got.System, got.Category, got.Title, tt.want.System, tt.want.Category, tt.want.Title)
+			}
+			if renamed := got.Filename("15.24"); renamed != tt.renamed {
+				t.Errorf("renamed to %q, want %q", renamed, tt.renamed)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// StaleAfter is how old a lock file must be before it is assumed to have
+// been left behind by a crashed process and is broken.
+const StaleAfter = 30 * time.Second
+
+// pollInterval is how often Acquire retries while the lock is held.
+const pollInterval = 50 * time.Millisecond
+
+// guardSuffix names the file, next to a lock file, held while the lock file is
+// removed, whether by its holder releasing it or by another process breaking it.
+const guardSuffix = ".break"
+
+// ErrTimeout is returned by Acquire when the lock could not be taken in time.
+var ErrTimeout = errors.New("timed out waiting for lock")
+
+// ErrLost is returned by Refresh when the lock was broken as stale and may now
+// be held by another process.
+var ErrLost = errors.New("lock lost")
+
+// Lock is an exclusive lock held through a lock file. Creating the file with
+// O_EXCL is atomic on local disks and on network shares, so a Lock also
+// serializes processes on different machines sharing the same root.
+type Lock struct {
+	path  string
+	owner string // Contents of the lock file, to tell it from a later one
+}
+
+// Acquire takes the lock at path, waiting up to timeout for another holder to
+// release it.
+func Acquire(path string, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			// Record the owner to help debugging a stuck lock
+			host, _ := os.Hostname()
+			owner := fmt.Sprintf("%s %d %d\n", host, os.Getpid(), time.Now().UnixNano())
+			_, err := f.WriteString(owner)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return &Lock{path: path, owner: owner}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > StaleAfter {
+			breakStale(path, info)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w %s", ErrTimeout, path)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// breakStale removes the stale lock file described by info. Other processes
+// may be breaking it at the same time, and one of them may already have
+// taken the lock afresh, so the file is only removed under the guard, and
+// only if it is still the one found stale. Otherwise it is left alone.
+func breakStale(path string, info os.FileInfo) {
+	release, ok := guard(path)
+	if !ok {
+		return // Someone else is breaking or releasing it
+	}
+	defer release()
+
+	current, err := os.Stat(path)
+	if err == nil && os.SameFile(info, current) && time.Since(current.ModTime()) > StaleAfter {
+		_ = os.Remove(path)
+	}
+}
+
+// guard takes the guard of the lock file at path, returning the function that
+// releases it, or false if another process holds it. The guard is only held
+// while a lock file is checked and removed, so one older than StaleAfter was
+// left behind by a crashed process; it is removed for the next attempt.
+func guard(path string) (func(), bool) {
+	guardPath := path + guardSuffix
+	f, err := os.OpenFile(guardPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		if info, err := os.Stat(guardPath); err == nil && time.Since(info.ModTime()) > StaleAfter {
+			_ = os.Remove(guardPath)
+		}
+		return nil, false
+	}
+	f.Close()
+	return func() { _ = os.Remove(guardPath) }, true
+}
+
+// Held reports whether a lock file exists at path and is not stale.
+func Held(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) <= StaleAfter
+}
+
+// Refresh marks the lock as still in use, so that long-running holders are
+// not mistaken for crashed ones. It returns ErrLost if the lock was broken.
+func (l *Lock) Refresh() error {
+	if !l.owned() {
+		return fmt.Errorf("%w %s", ErrLost, l.path)
+	}
+	now := time.Now()
+	return os.Chtimes(l.path, now, now)
+}
+
+// Release gives up the lock. If it was broken as stale in the meantime, the
+// lock file now belongs to another holder and is left alone.
+func (l *Lock) Release() error {
+	release, ok := guard(l.path)
+	for !ok {
+		time.Sleep(pollInterval)
+		release, ok = guard(l.path)
+	}
+	defer release()
+
+	if !l.owned() {
+		return nil
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// owned reports whether the lock file is still the one created by Acquire.
+func (l *Lock) owned() bool {
+	data, err := os.ReadFile(l.path)
+	return err == nil && string(data) == l.owner
+}
@@ -0,0 +1,153 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// makeStale backdates the file at path past StaleAfter.
+func makeStale(t *testing.T, path string) {
+	t.Helper()
+	old := time.Now().Add(-2 * StaleAfter)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAcquireExclusive has many goroutines take the lock at once, some
+// finding a stale lock to break first, and checks that only one holds it
+// at a time.
+func TestAcquireExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	makeStale(t, path)
+
+	var holders atomic.Int32
+	var wg sync.WaitGroup
+	for range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 5 {
+				l, err := Acquire(path, 10*time.Second)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if n := holders.Add(1); n != 1 {
+					t.Errorf("%d holders at once", n)
+				}
+				time.Sleep(time.Millisecond)
+				holders.Add(-1)
+				if err := l.Release(); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("lock file left behind: %v", err)
+	}
+}
+
+func TestAcquireTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	l, err := Acquire(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+
+	if _, err := Acquire(path, 100*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got %v, want %v", err, ErrTimeout)
+	}
+	if !Held(path) {
+		t.Fatal("lock not reported held")
+	}
+}
+
+func TestAcquireBreaksStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	if err := os.WriteFile(path, []byte("crashed 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	makeStale(t, path)
+	if Held(path) {
+		t.Fatal("stale lock reported held")
+	}
+
+	l, err := Acquire(path, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBreakStaleLeavesFreshLock breaks a lock found stale after another
+// process already broke it and took the lock afresh.
+func TestBreakStaleLeavesFreshLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	makeStale(t, path)
+	stale, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := Acquire(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	breakStale(path, stale)
+	if !fresh.owned() {
+		t.Fatal("fresh lock was broken")
+	}
+	if _, err := os.Stat(path + guardSuffix); !os.IsNotExist(err) {
+		t.Fatalf("guard left behind: %v", err)
+	}
+}
+
+// TestReleaseBroken releases a lock that was broken as stale and taken by
+// another holder, which must keep it.
+func TestReleaseBroken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	first, err := Acquire(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	makeStale(t, path)
+
+	second, err := Acquire(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Refresh(); !errors.Is(err, ErrLost) {
+		t.Fatalf("got %v, want %v", err, ErrLost)
+	}
+	if err := first.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if !second.owned() {
+		t.Fatal("releasing a broken lock removed the new holder's")
+	}
+	if err := second.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
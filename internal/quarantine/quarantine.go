@@ -0,0 +1,184 @@
+package quarantine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/scheme"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+)
+
+// LogFilename is the record of quarantined files in the state directory, one
+// JSON-encoded Record per line.
+const LogFilename = "quarantine.log"
+
+// Record says where a quarantined file came from and why it was quarantined.
+type Record struct {
+	Time   time.Time `json:"time"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+}
+
+// moveMu serialises moves into the holding folder, so that two files with the
+// same name never claim the same free path.
+var moveMu sync.Mutex
+
+// temporarySuffixes mark files that are still being written, such as partial downloads.
+var temporarySuffixes = []string{".part", ".partial", ".crdownload", ".download", ".tmp", "~"}
+
+// Skip reports whether a file must never be quarantined: hidden files, which
+// include jdd's own lock files, and files that are still being written.
+func Skip(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	for _, suffix := range temporarySuffixes {
+		if strings.HasSuffix(strings.ToLower(name), suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dir returns the holding folder under root, creating it if needed. A folder
+// named like something the scheme files, such as "00.01 Unsorted" with Johnny
+// Decimal, is kept in that place in the tree, under that name if the scheme's
+// folder prefix starts it and under the prefix otherwise; anything else is a
+// directory, relative to root unless absolute.
+func Dir(root, folder string, s scheme.Scheme) (string, error) {
+	segments := s.Parse(folder)
+	if segments == nil {
+		dir := utils.ExpandTilde(folder)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(root, dir)
+		}
+		return dir, os.MkdirAll(dir, 0755)
+	}
+
+	prefixes := s.Prefixes(segments)
+	parent, err := jd.EnsurePrefixedFolders(root, prefixes[:len(prefixes)-1])
+	if err != nil {
+		return "", err
+	}
+	last := prefixes[len(prefixes)-1]
+	dir, err := jd.FindPrefixedFolder(parent, last)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return dir, err
+	}
+	name := last
+	if jd.HasFolderPrefix(folder, last) {
+		name = folder
+	}
+	dir = filepath.Join(parent, name)
+	return dir, os.Mkdir(dir, 0755)
+}
+
+// Move moves the file at path into the holding folder dir (see Dir), without
+// overwriting anything, and records why in the log under root. Returns the
+// file's new path.
+func Move(root, dir, path, reason string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	moveMu.Lock()
+	defer moveMu.Unlock()
+
+	dest, err := filepath.Abs(freePath(dir, filepath.Base(path)))
+	if err != nil {
+		return "", err
+	}
+	if err := utils.MoveFile(path, dest); err != nil {
+		return "", err
+	}
+	return dest, record(root, Record{Time: time.Now(), From: path, To: dest, Reason: reason})
+}
+
+// Records returns the records of files still in the holding folder, oldest first.
+func Records(root string) ([]Record, error) {
+	data, err := os.ReadFile(logPath(root))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A file quarantined twice keeps its latest record
+	latest := make(map[string]int)
+	var records []Record
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("corrupt quarantine log: %w", err)
+		}
+		if i, ok := latest[r.To]; ok {
+			records[i] = r
+			continue
+		}
+		latest[r.To] = len(records)
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var present []Record
+	for _, r := range records {
+		if _, err := os.Stat(r.To); err == nil {
+			present = append(present, r)
+		}
+	}
+	return present, nil
+}
+
+// logPath returns the quarantine log for root.
+func logPath(root string) string {
+	return filepath.Join(root, config.StateDir, LogFilename)
+}
+
+// record appends r to the quarantine log.
+func record(root string, r Record) error {
+	if err := os.MkdirAll(filepath.Join(root, config.StateDir), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(logPath(root), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// freePath returns a path for name in dir that is not taken, adding " (2)",
+// " (3)" and so on before the extension if needed.
+func freePath(dir, name string) string {
+	path := filepath.Join(dir, name)
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", base, i, ext))
+	}
+}
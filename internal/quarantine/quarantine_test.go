@@ -0,0 +1,195 @@
+package quarantine
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/scheme"
+)
+
+func TestDir(t *testing.T) {
+	johnny, err := scheme.NewJohnnyDecimal(nil, jd.SubIDLayout{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dewey, err := scheme.NewDewey(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		scheme scheme.Scheme
+		folder string
+		want   string // Relative to root
+	}{
+		{johnny, "00.01 Unsorted", "00-09/00/00.01 Unsorted"},
+		{johnny, "Unsorted", "Unsorted"},
+		{johnny, "Inbox/Unsorted", "Inbox/Unsorted"},
+		{dewey, "00.01 Unsorted", "00.01 Unsorted"},
+		{dewey, "512 Unsorted", "500/510/512 Unsorted"},
+		{scheme.PARA{}, "00.01 Unsorted", "00.01 Unsorted"},
+		{scheme.PARA{}, "R-Unsorted", "Resources/Unsorted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.folder, func(t *testing.T) {
+			root := t.TempDir()
+
+			dir, err := Dir(root, tt.folder, tt.scheme)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(root, filepath.FromSlash(tt.want)); dir != want {
+				t.Fatalf("got %s, want %s", dir, want)
+			}
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				t.Fatalf("holding folder not created: %v", err)
+			}
+
+			// Found again, not created a second time
+			if again, err := Dir(root, tt.folder, tt.scheme); err != nil || again != dir {
+				t.Fatalf("got %s, %v the second time", again, err)
+			}
+		})
+	}
+}
+
+// TestDirExisting finds an ID folder already in the tree under another name.
+func TestDirExisting(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "00-09 System", "00 Meta", "00.01 Inbox")
+	if err := os.MkdirAll(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	johnny, err := scheme.NewJohnnyDecimal(nil, jd.SubIDLayout{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := Dir(root, "00.01 Unsorted", johnny)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != existing {
+		t.Fatalf("got %s, want %s", dir, existing)
+	}
+}
+
+func TestFreePath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"notes.txt", "notes (2).txt", "README"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"new.txt", "new.txt"},
+		{"notes.txt", "notes (3).txt"},
+		{"README", "README (2)"},
+	}
+	for _, tt := range tests {
+		if got := freePath(dir, tt.name); got != filepath.Join(dir, tt.want) {
+			t.Errorf("freePath(%q) = %s, want %s", tt.name, filepath.Base(got), tt.want)
+		}
+	}
+}
+
+// TestMove quarantines files with the same name at once, then checks that
+// none was overwritten and that each is recorded.
+func TestMove(t *testing.T) {
+	root := t.TempDir()
+	dir, err := Dir(root, "Unsorted", scheme.PARA{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 5
+	var wg sync.WaitGroup
+	for i := range n {
+		src := filepath.Join(root, "in", string(rune('a'+i)), "scan.pdf")
+		if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(src, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Move(root, dir, src, "no ID"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	records, err := Records(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != n {
+		t.Fatalf("got %d records, want %d", len(records), n)
+	}
+	seen := make(map[string]bool)
+	for _, r := range records {
+		data, err := os.ReadFile(r.To)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != r.From {
+			t.Errorf("%s holds %s, recorded as from %s", r.To, data, r.From)
+		}
+		if seen[r.To] || filepath.Dir(r.To) != dir || r.Reason != "no ID" {
+			t.Errorf("bad record %+v", r)
+		}
+		seen[r.To] = true
+	}
+}
+
+// TestRecords drops files no longer held and keeps the latest record of a
+// file quarantined twice.
+func TestRecords(t *testing.T) {
+	root := t.TempDir()
+	dir, err := Dir(root, "Unsorted", scheme.PARA{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(root, "scan.pdf")
+
+	var held string
+	for _, reason := range []string{"first", "second"} {
+		if err := os.WriteFile(src, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if held, err = Move(root, dir, src, reason); err != nil {
+			t.Fatal(err)
+		}
+		// Taken out again, as a user filing it by hand would
+		if reason == "first" {
+			if err := os.Rename(held, filepath.Join(root, "filed.pdf")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	records, err := Records(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].To != held || records[0].Reason != "second" {
+		t.Fatalf("got %+v, want only the second", records)
+	}
+
+	if err := os.Remove(held); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := Records(root); err != nil || len(records) != 0 {
+		t.Fatalf("got %+v, %v, want none", records, err)
+	}
+}
@@ -0,0 +1,119 @@
+package renumber
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+)
+
+// JournalFilename is the log of renumberings in the state directory, one
+// JSON-encoded entry per line.
+const JournalFilename = "renumber.log"
+
+// entry is a journal line: an Op tagged with the renumbering it belongs to.
+type entry struct {
+	Batch string `json:"batch"`
+	Op
+}
+
+// journal appends entries to the journal file.
+type journal struct {
+	f *os.File
+}
+
+// journalPath returns the journal file for root.
+func journalPath(root string) string {
+	return filepath.Join(root, config.StateDir, JournalFilename)
+}
+
+// openJournal opens the journal for appending.
+func openJournal(root string) (*journal, error) {
+	f, err := os.OpenFile(journalPath(root), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &journal{f: f}, nil
+}
+
+// record appends a completed operation to the journal.
+func (j *journal) record(batch string, op Op) error {
+	data, err := json.Marshal(entry{Batch: batch, Op: op})
+	if err != nil {
+		return err
+	}
+	_, err = j.f.Write(append(data, '\n'))
+	return err
+}
+
+// close closes the journal file.
+func (j *journal) close() error {
+	return j.f.Close()
+}
+
+// readJournal returns every entry in the journal, oldest first.
+func readJournal(root string) ([]entry, error) {
+	data, err := os.ReadFile(journalPath(root))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// lastBatch returns the operations of the most recent renumbering, in the
+// order they were applied.
+func lastBatch(root string) (string, []Op, error) {
+	entries, err := readJournal(root)
+	if err != nil || len(entries) == 0 {
+		return "", nil, err
+	}
+
+	batch := entries[len(entries)-1].Batch
+	var ops []Op
+	for _, e := range entries {
+		if e.Batch == batch {
+			ops = append(ops, e.Op)
+		}
+	}
+	return batch, ops, nil
+}
+
+// dropBatch removes a renumbering from the journal once it has been undone.
+func dropBatch(root string, batch string) error {
+	entries, err := readJournal(root)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		if e.Batch == batch {
+			continue
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	}
+	return os.WriteFile(journalPath(root), buf.Bytes(), 0644)
+}
@@ -0,0 +1,272 @@
+package renumber
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/excluder"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/lock"
+	"github.com/mahyarmirrashed/jdd/internal/tree"
+)
+
+// LockFilename is the lock file in the state directory held while renumbering.
+// The daemon holds off processing while it exists.
+const LockFilename = "renumber.lock"
+
+// lockTimeout is how long to wait for another renumbering to finish.
+const lockTimeout = 10 * time.Second
+
+// Operations recorded in the journal.
+const (
+	OpMkdir  = "mkdir"  // A folder was created
+	OpRename = "rename" // A file or folder was renamed or moved
+)
+
+// Op is a single filesystem change made while renumbering. Paths are
+// relative to root.
+type Op struct {
+	Op   string `json:"op"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to"`
+}
+
+// LockPath returns the renumber lock file for root.
+func LockPath(root string) string {
+	return filepath.Join(root, config.StateDir, LockFilename)
+}
+
+// InProgress reports whether a renumbering is currently running under root.
+func InProgress(root string) bool {
+	return lock.Held(LockPath(root))
+}
+
+// Plan works out the changes needed to renumber ID from to ID to under root:
+// the ID folder is moved under the right area and category and renamed, and
+// every file and sub-ID folder inside it carrying the old ID is renamed. An ID
+// folder protected by an opt-out marker, or holding one, is not renumbered.
+func Plan(root, from, to string) ([]Op, error) {
+	src, dst, err := parseIDs(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	srcPath, err := src.FindFolders(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no folder for %s under %s", from, root)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The destination may already exist under another category
+	if existing, err := findID(root, dst.ID); err != nil {
+		return nil, err
+	} else if existing != "" {
+		return nil, fmt.Errorf("%s already exists: %s", to, existing)
+	}
+	// Everything inside the folder moves with it
+	if reason, err := excluder.SubtreeMarker(root, srcPath); err != nil {
+		return nil, err
+	} else if reason != "" {
+		return nil, fmt.Errorf("not renumbering %s: %s", from, reason)
+	}
+
+	var ops []Op
+
+	// Area and category folders the destination needs
+	parent := root
+	for _, prefix := range []string{dst.Area, dst.Category} {
+		dir, err := jd.FindPrefixedFolder(parent, prefix)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if err != nil {
+			dir = filepath.Join(parent, prefix)
+			ops = append(ops, Op{Op: OpMkdir, To: rel(root, dir)})
+		}
+		parent = dir
+	}
+
+	dstPath := filepath.Join(parent, dst.ID+strings.TrimPrefix(filepath.Base(srcPath), src.ID))
+	ops = append(ops, Op{Op: OpRename, From: rel(root, srcPath), To: rel(root, dstPath)})
+
+	// Rename children deepest first, so that each path is still valid when
+	// its turn comes. WalkDir visits parents before children, so reverse it.
+	var renames []Op
+	err = filepath.WalkDir(srcPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == srcPath || !strings.HasPrefix(d.Name(), src.ID) {
+			return nil
+		}
+
+		dir := filepath.Join(dstPath, strings.TrimPrefix(filepath.Dir(path), srcPath))
+		renames = append(renames, Op{
+			Op:   OpRename,
+			From: rel(root, filepath.Join(dir, d.Name())),
+			To:   rel(root, filepath.Join(dir, dst.ID+strings.TrimPrefix(d.Name(), src.ID))),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := len(renames) - 1; i >= 0; i-- {
+		ops = append(ops, renames[i])
+	}
+
+	return ops, nil
+}
+
+// Apply performs the planned changes while holding the renumber lock,
+// recording each one in the journal so that it can be undone.
+func Apply(root string, ops []Op) error {
+	l, err := acquire(root)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	j, err := openJournal(root)
+	if err != nil {
+		return err
+	}
+	defer j.close()
+
+	batch := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, op := range ops {
+		switch op.Op {
+		case OpMkdir:
+			err = os.Mkdir(abs(root, op.To), 0755)
+		case OpRename:
+			err = rename(abs(root, op.From), abs(root, op.To))
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", op.Op, op.To, err)
+		}
+		if err := j.record(batch, op); err != nil {
+			return err
+		}
+		_ = l.Refresh()
+	}
+
+	return nil
+}
+
+// Undo reverts the most recent renumbering recorded in the journal and
+// returns the changes it reverted, in the order they were undone.
+func Undo(root string, dryRun bool) ([]Op, error) {
+	l, err := acquire(root)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+
+	batch, ops, err := lastBatch(root)
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) == 0 {
+		return nil, fmt.Errorf("nothing to undo")
+	}
+
+	var undone []Op
+	for i := len(ops) - 1; i >= 0; i-- {
+		op := ops[i]
+		if !dryRun {
+			switch op.Op {
+			case OpMkdir:
+				// Only succeeds if nothing else was filed there since
+				if err := os.Remove(abs(root, op.To)); err != nil && !os.IsNotExist(err) {
+					continue
+				}
+			case OpRename:
+				if err := rename(abs(root, op.To), abs(root, op.From)); err != nil {
+					return undone, fmt.Errorf("undo %s: %w", op.To, err)
+				}
+			}
+			_ = l.Refresh()
+		}
+		undone = append(undone, op)
+	}
+
+	if dryRun {
+		return undone, nil
+	}
+	return undone, dropBatch(root, batch)
+}
+
+// acquire takes the renumber lock for root, creating the state directory if needed.
+func acquire(root string) (*lock.Lock, error) {
+	if err := os.MkdirAll(filepath.Join(root, config.StateDir), 0755); err != nil {
+		return nil, err
+	}
+	return lock.Acquire(LockPath(root), lockTimeout)
+}
+
+// rename moves from to to, refusing to overwrite anything at to.
+func rename(from, to string) error {
+	if _, err := os.Lstat(to); err == nil {
+		return fmt.Errorf("%s already exists", to)
+	}
+	return os.Rename(from, to)
+}
+
+// findID returns the folder for ID number anywhere under root, or "" if
+// there is none.
+func findID(root, number string) (string, error) {
+	t, err := tree.Load(root)
+	if err != nil {
+		return "", err
+	}
+	for _, area := range t.Areas {
+		for _, category := range area.Categories {
+			if id := category.ID(number); id != nil {
+				return id.Path, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// parseIDs parses and checks the source and destination IDs.
+func parseIDs(from, to string) (*jd.JohnnyDecimal, *jd.JohnnyDecimal, error) {
+	var ids []*jd.JohnnyDecimal
+	for _, id := range []string{from, to} {
+		m := jd.IDFolderPattern.FindStringSubmatch(id)
+		if m == nil || m[3] != "" {
+			return nil, nil, fmt.Errorf("invalid ID %q (expected e.g. 15.23)", id)
+		}
+		jdObj, err := jd.Parse(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, jdObj)
+	}
+
+	if ids[0].ID == ids[1].ID {
+		return nil, nil, fmt.Errorf("%s and %s are the same ID", from, to)
+	}
+	return ids[0], ids[1], nil
+}
+
+// rel returns path relative to root, using '/' as the separator.
+func rel(root, path string) string {
+	r, err := filepath.Rel(root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(r)
+}
+
+// abs returns the absolute path of a journal path relative to root.
+func abs(root, path string) string {
+	return filepath.Join(root, filepath.FromSlash(path))
+}
@@ -0,0 +1,195 @@
+package renumber
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// makeTree creates the folders and empty files under root. Paths ending in
+// "/" are folders.
+func makeTree(t *testing.T, root string, paths ...string) {
+	t.Helper()
+	for _, p := range paths {
+		path := filepath.Join(root, filepath.FromSlash(p))
+		if strings.HasSuffix(p, "/") {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkTree fails unless every path exists under root and none of gone does.
+func checkTree(t *testing.T, root string, paths, gone []string) {
+	t.Helper()
+	for _, p := range paths {
+		if _, err := os.Stat(abs(root, p)); err != nil {
+			t.Errorf("%s missing", p)
+		}
+	}
+	for _, p := range gone {
+		if _, err := os.Stat(abs(root, p)); err == nil {
+			t.Errorf("%s still exists", p)
+		}
+	}
+}
+
+// japan is the ID folder renumbered by the tests, with a file and a sub-ID.
+var japan = []string{
+	"10-19 Life/15 Travel/15.23 Japan/15.23 ticket.pdf",
+	"10-19 Life/15 Travel/15.23 Japan/15.23+JEM Hotels/15.23+JEM booking.pdf",
+	"10-19 Life/15 Travel/15.23 Japan/notes.txt",
+}
+
+func TestRenumber(t *testing.T) {
+	tests := []struct {
+		to     string
+		mkdirs int      // Area and category folders created
+		want   []string // Paths afterwards
+	}{
+		{"15.24", 0, []string{
+			"10-19 Life/15 Travel/15.24 Japan/15.24 ticket.pdf",
+			"10-19 Life/15 Travel/15.24 Japan/15.24+JEM Hotels/15.24+JEM booking.pdf",
+			"10-19 Life/15 Travel/15.24 Japan/notes.txt",
+		}},
+		{"16.04", 0, []string{
+			"10-19 Life/16 Moving/16.04 Japan/16.04 ticket.pdf",
+			"10-19 Life/16 Moving/16.04 Japan/16.04+JEM Hotels/16.04+JEM booking.pdf",
+		}},
+		{"21.05", 2, []string{
+			"20-29/21/21.05 Japan/21.05 ticket.pdf",
+			"20-29/21/21.05 Japan/21.05+JEM Hotels/21.05+JEM booking.pdf",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.to, func(t *testing.T) {
+			root := t.TempDir()
+			makeTree(t, root, append(japan, "10-19 Life/16 Moving/")...)
+
+			ops, err := Plan(root, "15.23", tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mkdirs := 0
+			for _, op := range ops {
+				if op.Op == OpMkdir {
+					mkdirs++
+				}
+			}
+			if mkdirs != tt.mkdirs {
+				t.Errorf("got %d mkdirs, want %d: %+v", mkdirs, tt.mkdirs, ops)
+			}
+
+			if err := Apply(root, ops); err != nil {
+				t.Fatal(err)
+			}
+			checkTree(t, root, tt.want, []string{"10-19 Life/15 Travel/15.23 Japan"})
+
+			if _, err := Undo(root, false); err != nil {
+				t.Fatal(err)
+			}
+			checkTree(t, root, japan, tt.want[:1])
+			if tt.mkdirs > 0 {
+				checkTree(t, root, nil, []string{"20-29"})
+			}
+		})
+	}
+}
+
+func TestPlanRefuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		extra    string // Added to the tree
+		want     string // In the error
+	}{
+		{"target exists", "15.23", "15.24", "10-19 Life/15 Travel/15.24 Korea/", "already exists"},
+		{"target elsewhere", "15.23", "16.04", "10-19 Life/17 Admin/16.04 Visa/", "already exists"},
+		{"missing", "15.22", "15.24", "", "no folder"},
+		{"same", "15.23", "15.23", "", "same ID"},
+		{"not an ID", "15", "16", "", "invalid ID"},
+		{"protected", "15.23", "15.24", "10-19 Life/15 Travel/15.23 Japan/.jdkeep", "not renumbering"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			makeTree(t, root, japan...)
+			if tt.extra != "" {
+				makeTree(t, root, tt.extra)
+			}
+
+			ops, err := Plan(root, tt.from, tt.to)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v (ops %+v), want an error containing %q", err, ops, tt.want)
+			}
+		})
+	}
+}
+
+// TestUndoPartial undoes a renumbering that failed part way through.
+func TestUndoPartial(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, japan...)
+
+	ops, err := Plan(root, "15.23", "21.05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Appears after planning and blocks renaming the ticket
+	makeTree(t, root, "10-19 Life/15 Travel/15.23 Japan/21.05 ticket.pdf")
+
+	if err := Apply(root, ops); err == nil {
+		t.Fatal("renumbering over an existing file succeeded")
+	}
+	checkTree(t, root, []string{"20-29/21/21.05 Japan/15.23 ticket.pdf"}, nil)
+
+	undone, err := Undo(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undone) == 0 || len(undone) >= len(ops) {
+		t.Fatalf("undid %d of %d changes", len(undone), len(ops))
+	}
+	checkTree(t, root, japan, []string{"20-29"})
+
+	if _, err := Undo(root, false); err == nil || err.Error() != "nothing to undo" {
+		t.Fatalf("got %v, want nothing to undo", err)
+	}
+}
+
+// TestUndoDryRun reports the changes without making them or forgetting them.
+func TestUndoDryRun(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, japan...)
+
+	ops, err := Plan(root, "15.23", "15.24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(root, ops); err != nil {
+		t.Fatal(err)
+	}
+
+	undone, err := Undo(root, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(undone) != len(ops) {
+		t.Fatalf("would undo %d of %d changes", len(undone), len(ops))
+	}
+	checkTree(t, root, []string{"10-19 Life/15 Travel/15.24 Japan/15.24 ticket.pdf"}, nil)
+
+	if _, err := Undo(root, false); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(t, root, japan, nil)
+}
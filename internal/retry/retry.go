@@ -0,0 +1,194 @@
+package retry
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+)
+
+// QueueFilename is the retry queue in the state directory: files whose move
+// failed with an error that may clear up on its own.
+const QueueFilename = "retry.json"
+
+// Defaults for the number of attempts and the delay before the first retry.
+const (
+	DefaultAttempts = 5
+	DefaultBackoff  = 2 * time.Second
+)
+
+// maxBackoff caps the delay between two attempts.
+const maxBackoff = time.Hour
+
+// Entry is a file waiting to be retried.
+type Entry struct {
+	Path     string    `json:"path"`
+	Attempts int       `json:"attempts"` // Failed attempts so far
+	Next     time.Time `json:"next"`     // When to try again
+	Error    string    `json:"error"`    // Last error
+}
+
+// Queue is the retry queue of a root, saved to its state directory on every
+// change. It is safe for concurrent use.
+type Queue struct {
+	mu       sync.Mutex
+	root     string
+	attempts int
+	backoff  time.Duration
+	entries  map[string]*Entry
+}
+
+// Transient reports whether err may clear up on its own: the file is briefly
+// locked or busy, or it or its folder vanished in the middle of a rename chain.
+// An operation the system never permits, like moving an immutable file
+// (EPERM), is not transient.
+func Transient(err error) bool {
+	if errors.Is(err, fs.ErrNotExist) {
+		return true
+	}
+	for _, errno := range transientErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads the retry queue of root. A file is tried at most attempts times,
+// waiting backoff before the first retry and twice as long before each next one.
+// Zero values mean DefaultAttempts and DefaultBackoff.
+func Load(root string, attempts int, backoff time.Duration) (*Queue, error) {
+	if attempts == 0 {
+		attempts = DefaultAttempts
+	}
+	if backoff == 0 {
+		backoff = DefaultBackoff
+	}
+
+	entries, err := readEntries(root)
+	if err != nil {
+		return nil, err
+	}
+	q := &Queue{root: root, attempts: attempts, backoff: backoff, entries: make(map[string]*Entry)}
+	for i := range entries {
+		q.entries[entries[i].Path] = &entries[i]
+	}
+	return q, nil
+}
+
+// Fail records a failed attempt to file path. Returns how long until the next
+// attempt, or 0 if the file has run out of attempts and was dropped.
+func (q *Queue) Fail(path string, cause error) (time.Duration, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	e, ok := q.entries[path]
+	if !ok {
+		e = &Entry{Path: path}
+		q.entries[path] = e
+	}
+	e.Attempts++
+	e.Error = cause.Error()
+
+	// The first attempt was not a retry, so it counts too
+	if e.Attempts >= q.attempts {
+		delete(q.entries, path)
+		return 0, q.save()
+	}
+
+	delay := q.backoff << (e.Attempts - 1)
+	if delay > maxBackoff || delay <= 0 {
+		delay = maxBackoff
+	}
+	e.Next = time.Now().Add(delay)
+	return delay, q.save()
+}
+
+// Done drops path from the queue, if it is queued.
+func (q *Queue) Done(path string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.entries[path]; !ok {
+		return nil
+	}
+	delete(q.entries, path)
+	return q.save()
+}
+
+// Due returns the queued paths whose next attempt is due at now, soonest first.
+func (q *Queue) Due(now time.Time) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var due []*Entry
+	for _, e := range q.entries {
+		if !e.Next.After(now) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Next.Before(due[j].Next) })
+
+	paths := make([]string, len(due))
+	for i, e := range due {
+		paths[i] = e.Path
+	}
+	return paths
+}
+
+// Entries returns the files queued under root, soonest retry first.
+func Entries(root string) ([]Entry, error) {
+	entries, err := readEntries(root)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Next.Before(entries[j].Next) })
+	return entries, nil
+}
+
+// queuePath returns the retry queue file for root.
+func queuePath(root string) string {
+	return filepath.Join(root, config.StateDir, QueueFilename)
+}
+
+// readEntries reads the retry queue file.
+func readEntries(root string) ([]Entry, error) {
+	data, err := os.ReadFile(queuePath(root))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// save replaces the queue file.
+func (q *Queue) save() error {
+	if err := os.MkdirAll(filepath.Join(q.root, config.StateDir), 0755); err != nil {
+		return err
+	}
+
+	entries := make([]Entry, 0, len(q.entries))
+	for _, e := range q.entries {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(queuePath(q.root), data, 0644)
+}
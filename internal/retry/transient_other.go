@@ -0,0 +1,6 @@
+//go:build !unix && !windows
+
+package retry
+
+// transientErrnos is empty: only a vanished file is known to be transient here.
+var transientErrnos []error
@@ -0,0 +1,10 @@
+//go:build unix
+
+package retry
+
+import "syscall"
+
+// transientErrnos are the errors of a file that is locked or busy for now.
+// EACCES is among them as sync clients briefly lock folders down while they
+// write; EPERM is not.
+var transientErrnos = []error{syscall.EACCES, syscall.EBUSY, syscall.ETXTBSY, syscall.EAGAIN}
@@ -0,0 +1,30 @@
+//go:build unix
+
+package retry
+
+import (
+	"fmt"
+	"io/fs"
+	"syscall"
+	"testing"
+)
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{syscall.ENOENT, true},
+		{syscall.EBUSY, true},
+		{syscall.EACCES, true},
+		{syscall.EPERM, false},
+		{syscall.ENOSPC, false},
+		{fs.ErrExist, false},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("moving: %w", &fs.PathError{Op: "rename", Path: "15.23 ticket.pdf", Err: tt.err})
+		if got := Transient(err); got != tt.want {
+			t.Errorf("Transient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
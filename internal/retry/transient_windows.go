@@ -0,0 +1,9 @@
+//go:build windows
+
+package retry
+
+import "golang.org/x/sys/windows"
+
+// transientErrnos are the errors of a file that is locked or busy for now:
+// open in another program without sharing, locked by it, or pending deletion.
+var transientErrnos = []error{windows.ERROR_SHARING_VIOLATION, windows.ERROR_LOCK_VIOLATION, windows.ERROR_ACCESS_DENIED}
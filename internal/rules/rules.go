@@ -0,0 +1,256 @@
+package rules
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Rule files files without an ID in their name under an ID. Every condition
+// that is set must hold for the rule to match.
+type Rule struct {
+	Name    string   `yaml:"name"`     // Shown in logs; defaults to the rule's position
+	Match   string   `yaml:"match"`    // Regular expression on the filename
+	Glob    string   `yaml:"glob"`     // Glob on the filename, e.g. "invoice_*.pdf"
+	Ext     []string `yaml:"ext"`      // Extensions, with or without the dot; any one matches
+	MIME    string   `yaml:"mime"`     // Sniffed MIME type, or a prefix of it such as "image/"
+	From    string   `yaml:"from"`     // Directory the file must be in or below, e.g. "~/Downloads"
+	MinSize string   `yaml:"min_size"` // Smallest matching size, e.g. "10KB"
+	MaxSize string   `yaml:"max_size"` // Largest matching size, e.g. "5MB"
+	ID      string   `yaml:"id"`       // Where matching files are filed, e.g. "12.04"
+	Rename  bool     `yaml:"rename"`   // If true, prefix the filename with the ID
+}
+
+// Set is a list of compiled rules, evaluated in order.
+type Set struct {
+	rules []*compiled
+}
+
+// compiled is a rule with its conditions parsed.
+type compiled struct {
+	*Rule
+	match   *regexp.Regexp
+	glob    glob.Glob
+	from    string
+	minSize int64
+	maxSize int64
+}
+
+// Load reads the rules from the "rules" key of a YAML config file. A missing
+// file has no rules.
+func Load(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Rules []Rule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid rules in %s: %w", path, err)
+	}
+	return file.Rules, nil
+}
+
+// Compile parses the conditions of rules. Relative "from" directories are
+// taken from root. A "from" directory must be inside root or be one of
+// inboxes, which must be absolute, as no other directory is watched.
+func Compile(rules []Rule, root string, inboxes []string) (*Set, error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &Set{}
+	for i := range rules {
+		r := &compiled{Rule: &rules[i], maxSize: -1}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if r.ID == "" {
+			return nil, fmt.Errorf("%s: no id to file under", r.Name)
+		}
+
+		var err error
+		if r.Rule.Match != "" {
+			if r.match, err = regexp.Compile(r.Rule.Match); err != nil {
+				return nil, fmt.Errorf("%s: invalid match: %w", r.Name, err)
+			}
+		}
+		if r.Rule.Glob != "" {
+			if r.glob, err = glob.Compile(r.Rule.Glob); err != nil {
+				return nil, fmt.Errorf("%s: invalid glob: %w", r.Name, err)
+			}
+		}
+		if r.From != "" {
+			r.from = utils.ExpandTilde(r.From)
+			if !filepath.IsAbs(r.from) {
+				r.from = filepath.Join(root, r.from)
+			}
+			if r.from, err = filepath.Abs(r.from); err != nil {
+				return nil, err
+			}
+			if !watched(r.from, absRoot, inboxes) {
+				return nil, fmt.Errorf("%s: from %s is neither inside root nor an inbox, so it is not watched", r.Name, r.From)
+			}
+		}
+		if r.MinSize != "" {
+			if r.minSize, err = ParseSize(r.MinSize); err != nil {
+				return nil, fmt.Errorf("%s: invalid min_size: %w", r.Name, err)
+			}
+		}
+		if r.MaxSize != "" {
+			if r.maxSize, err = ParseSize(r.MaxSize); err != nil {
+				return nil, fmt.Errorf("%s: invalid max_size: %w", r.Name, err)
+			}
+		}
+		set.rules = append(set.rules, r)
+	}
+	return set, nil
+}
+
+// watched reports whether files arriving in dir are seen: dir is in or below
+// root, or one of inboxes.
+func watched(dir, root string, inboxes []string) bool {
+	if dir == root || strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		return true
+	}
+	for _, inbox := range inboxes {
+		if dir == inbox {
+			return true
+		}
+	}
+	return false
+}
+
+// Rules returns the rules in the set, in order.
+func (s *Set) Rules() []*Rule {
+	if s == nil {
+		return nil
+	}
+	rules := make([]*Rule, len(s.rules))
+	for i, r := range s.rules {
+		rules[i] = r.Rule
+	}
+	return rules
+}
+
+// Match returns the first rule matching the file at path, or nil. Rules
+// without a "from" directory only match files directly in one of inboxes,
+// so that they never pull files out of the filed tree.
+func (s *Set) Match(path string, inboxes []string) *Rule {
+	if s == nil {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+
+	var mime string
+	for _, r := range s.rules {
+		if !r.inScope(path, inboxes) || !r.matchName(filepath.Base(path)) {
+			continue
+		}
+		if info.Size() < r.minSize || (r.maxSize >= 0 && info.Size() > r.maxSize) {
+			continue
+		}
+		if r.MIME != "" {
+			if mime == "" {
+				mime = sniff(path)
+			}
+			if !strings.HasPrefix(mime, r.MIME) {
+				continue
+			}
+		}
+		return r.Rule
+	}
+	return nil
+}
+
+// inScope reports whether the rule applies to files in the directory of path.
+// Inboxes must be absolute.
+func (r *compiled) inScope(path string, inboxes []string) bool {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+	if r.from != "" {
+		return dir == r.from || strings.HasPrefix(dir, r.from+string(filepath.Separator))
+	}
+	for _, inbox := range inboxes {
+		if dir == inbox {
+			return true
+		}
+	}
+	return false
+}
+
+// matchName checks the filename conditions of the rule.
+func (r *compiled) matchName(name string) bool {
+	if r.match != nil && !r.match.MatchString(name) {
+		return false
+	}
+	if r.glob != nil && !r.glob.Match(name) {
+		return false
+	}
+	if len(r.Ext) > 0 {
+		ext := strings.TrimPrefix(filepath.Ext(name), ".")
+		found := false
+		for _, want := range r.Ext {
+			if strings.EqualFold(ext, strings.TrimPrefix(want, ".")) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// sniff returns the MIME type of the file at path, detected from its content.
+func sniff(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, _ := f.Read(buf)
+	return http.DetectContentType(buf[:n])
+}
+
+// sizePattern matches a size like "512", "10KB" or "1.5 GB".
+var sizePattern = regexp.MustCompile(`(?i)^\s*(\d+(?:\.\d+)?)\s*(B|KB|MB|GB|TB)?\s*$`)
+
+// sizeUnits maps size suffixes to bytes.
+var sizeUnits = map[string]float64{"": 1, "B": 1, "KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30, "TB": 1 << 40}
+
+// ParseSize parses a size like "10KB" or "1.5GB" into bytes; units are powers of 1024.
+func ParseSize(s string) (int64, error) {
+	m := sizePattern.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(n * sizeUnits[strings.ToUpper(m[2])]), nil
+}
@@ -0,0 +1,76 @@
+package scheme
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+)
+
+// DefaultDeweyDepth is the number of Dewey levels used when none is configured:
+// hundreds, tens and units.
+const DefaultDeweyDepth = 3
+
+// deweyPattern matches a Dewey-style class like "512" or "512.734" at the
+// start of a filename.
+var deweyPattern = regexp.MustCompile(`^(\d{3})(?:\.(\d+))?(?:[\s_-]|$)`)
+
+// Dewey files names like "512.734 Algebra.pdf" under one folder per level:
+// "500", "510", "512", then one more decimal digit per level beyond the
+// third ("512.7", "512.73", ...), up to Depth levels.
+type Dewey struct {
+	Depth int
+}
+
+// NewDewey returns the Dewey scheme with the given number of levels, or
+// DefaultDeweyDepth if depth is 0.
+func NewDewey(depth int) (Dewey, error) {
+	if depth == 0 {
+		depth = DefaultDeweyDepth
+	}
+	if depth < 1 {
+		return Dewey{}, fmt.Errorf("invalid Dewey depth %d", depth)
+	}
+	return Dewey{Depth: depth}, nil
+}
+
+// Parse returns the classes of a filename from the hundreds down. A class
+// that repeats its parent, like the tens of "500", is left out.
+func (s Dewey) Parse(filename string) []string {
+	m := deweyPattern.FindStringSubmatch(filename)
+	if m == nil {
+		return nil
+	}
+	class, decimals := m[1], m[2]
+
+	levels := []string{class[:1] + "00", class[:2] + "0", class}
+	for i := 1; i <= len(decimals); i++ {
+		levels = append(levels, class+"."+decimals[:i])
+	}
+	if len(levels) > s.Depth {
+		levels = levels[:s.Depth]
+	}
+
+	var segments []string
+	for _, level := range levels {
+		if len(segments) == 0 || segments[len(segments)-1] != level {
+			segments = append(segments, level)
+		}
+	}
+	return segments
+}
+
+// Prefixes returns the segments unchanged: Dewey folders start with their class.
+func (s Dewey) Prefixes(segments []string) []string {
+	return segments
+}
+
+// Placeholder returns nil: Dewey classes are never allocated.
+func (s Dewey) Placeholder(filename string) *jd.Placeholder {
+	return nil
+}
+
+// JohnnyDecimalFolders returns false: Dewey classes are not JD numbers.
+func (s Dewey) JohnnyDecimalFolders() bool {
+	return false
+}
@@ -0,0 +1,57 @@
+package scheme
+
+import (
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+)
+
+// JohnnyDecimal files names like "15.23 Japan.pdf" under area, category and
+// ID folders ("10-19", "15", "15.23"), and a sub-ID folder if there is one.
+type JohnnyDecimal struct {
+	patterns []*jd.FilePattern
+	layout   jd.SubIDLayout
+}
+
+// NewJohnnyDecimal returns the Johnny Decimal scheme, recognising IDs with the
+// given filename patterns (see jd.ParsePatterns) and nesting sub-IDs by layout.
+func NewJohnnyDecimal(patterns []string, layout jd.SubIDLayout) (*JohnnyDecimal, error) {
+	compiled, err := jd.ParsePatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+	return &JohnnyDecimal{patterns: compiled, layout: layout}, nil
+}
+
+// Parse returns the area, category, ID and sub-ID levels, if any, of a filename.
+func (s *JohnnyDecimal) Parse(filename string) []string {
+	jdObj, _ := jd.Match(s.patterns, filename)
+	if jdObj == nil {
+		return nil
+	}
+	jdObj.ApplyLayout(s.layout)
+	return jdObj.FolderPrefixes()
+}
+
+// Prefixes returns the segments unchanged: JD folders start with their number.
+func (s *JohnnyDecimal) Prefixes(segments []string) []string {
+	return segments
+}
+
+// Placeholder returns the placeholder ID, like "15.xx", that the filename
+// patterns find in a filename.
+func (s *JohnnyDecimal) Placeholder(filename string) *jd.Placeholder {
+	return jd.MatchPlaceholder(s.patterns, filename)
+}
+
+// JohnnyDecimalFolders returns true.
+func (s *JohnnyDecimal) JohnnyDecimalFolders() bool {
+	return true
+}
+
+// System returns the system code of a multi-system ID like "P01.15.23".
+func (s *JohnnyDecimal) System(filename string) string {
+	jdObj, _ := jd.Match(s.patterns, filename)
+	if jdObj == nil {
+		return ""
+	}
+	return jdObj.System
+}
@@ -0,0 +1,47 @@
+package scheme
+
+import (
+	"regexp"
+
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+)
+
+// paraPattern matches a PARA keyword prefix like "P-Website" at the start of a filename.
+var paraPattern = regexp.MustCompile(`^([PAR])-([^\s_.]+)`)
+
+// paraFolders maps PARA letters to their top-level folders.
+var paraFolders = map[string]string{
+	"P": "Projects",
+	"A": "Areas",
+	"R": "Resources",
+}
+
+// PARA files names like "P-Website brief.pdf" under the folder for the
+// letter ("Projects", "Areas" or "Resources") and then the keyword ("Website").
+type PARA struct{}
+
+// Parse returns the letter and keyword of a filename.
+func (PARA) Parse(filename string) []string {
+	m := paraPattern.FindStringSubmatch(filename)
+	if m == nil {
+		return nil
+	}
+	return []string{m[1], m[2]}
+}
+
+// Prefixes names the top-level folder after the letter and keeps the keyword.
+func (PARA) Prefixes(segments []string) []string {
+	prefixes := append([]string{}, segments...)
+	prefixes[0] = paraFolders[segments[0]]
+	return prefixes
+}
+
+// Placeholder returns nil: PARA keywords are never allocated.
+func (PARA) Placeholder(filename string) *jd.Placeholder {
+	return nil
+}
+
+// JohnnyDecimalFolders returns false: PARA folders are named by keyword.
+func (PARA) JohnnyDecimalFolders() bool {
+	return false
+}
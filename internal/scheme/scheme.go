@@ -0,0 +1,78 @@
+package scheme
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+)
+
+// Built-in scheme names.
+const (
+	NameJohnnyDecimal = "johnny-decimal"
+	NameDewey         = "dewey"
+	NamePARA          = "para"
+)
+
+// Names lists the built-in schemes.
+var Names = []string{NameJohnnyDecimal, NameDewey, NamePARA}
+
+// Scheme classifies filenames into a folder hierarchy.
+type Scheme interface {
+	// Parse returns the hierarchy segments a filename carries, outermost
+	// first, or nil if it carries none.
+	Parse(filename string) []string
+	// Prefixes maps hierarchy segments to the folder name prefix at each level.
+	Prefixes(segments []string) []string
+	// Placeholder returns the placeholder ID a filename carries, asking for
+	// the next free ID in a category, or nil if it carries none.
+	Placeholder(filename string) *jd.Placeholder
+	// JohnnyDecimalFolders reports whether the scheme files into Johnny
+	// Decimal area, category and ID folders. Suggestions, folder fixes and
+	// holding files left loose in an area or category rely on that layout.
+	JohnnyDecimalFolders() bool
+}
+
+// SystemScheme is implemented by schemes whose filenames can name the system
+// they belong to, which routes them to that system's root.
+type SystemScheme interface {
+	Scheme
+	// System returns the system code a filename carries, or "".
+	System(filename string) string
+}
+
+// New returns the scheme configured in cfg, Johnny Decimal by default.
+func New(cfg *config.Config) (Scheme, error) {
+	switch cfg.Scheme {
+	case "", NameJohnnyDecimal:
+		return NewJohnnyDecimal(cfg.Patterns, cfg.SubIDLayout())
+	case NameDewey:
+		return NewDewey(cfg.DeweyDepth)
+	case NamePARA:
+		return PARA{}, nil
+	default:
+		return nil, fmt.Errorf("unknown scheme %q, expected one of %v", cfg.Scheme, Names)
+	}
+}
+
+// EnsureFolders ensures the folders for segments exist under root and returns
+// the innermost one.
+func EnsureFolders(s Scheme, root string, segments []string) (string, error) {
+	return jd.EnsurePrefixedFolders(root, s.Prefixes(segments))
+}
+
+// FindFolders returns the folder EnsureFolders would return for segments
+// under root, without creating any: existing folders are used where found,
+// and folders named after their prefix where not.
+func FindFolders(s Scheme, root string, segments []string) string {
+	path := root
+	for _, prefix := range s.Prefixes(segments) {
+		if found, err := jd.FindPrefixedFolder(path, prefix); err == nil {
+			path = found
+		} else {
+			path = filepath.Join(path, prefix)
+		}
+	}
+	return path
+}
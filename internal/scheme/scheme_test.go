@@ -0,0 +1,68 @@
+package scheme
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+)
+
+// TestEnsureFolders files names of each scheme into a tree already holding
+// folders whose names only start like the ones they need, and checks that
+// FindFolders foresees the same folder without creating it.
+func TestEnsureFolders(t *testing.T) {
+	dewey, err := NewDewey(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	johnny, err := NewJohnnyDecimal(nil, jd.SubIDLayout{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		scheme   Scheme
+		existing []string // Folders already there
+		filename string
+		want     string // Folder filed into
+	}{
+		{PARA{}, []string{"Projects/Website"}, "P-Web brief.pdf", "Projects/Web"},
+		{PARA{}, []string{"Projects/Web design"}, "P-Web brief.pdf", "Projects/Web design"},
+		{PARA{}, []string{"Areas/Cards"}, "A-Car insurance.pdf", "Areas/Car"},
+		{dewey, []string{"500/510/5120"}, "512 Algebra.pdf", "500/510/512"},
+		{dewey, []string{"500 Science/510/512 Algebra"}, "512 Algebra.pdf", "500 Science/510/512 Algebra"},
+		{johnny, []string{"10-19/15/15.234"}, "15.23 Japan.pdf", "10-19/15/15.23"},
+		{johnny, []string{"10-19 Life/15 Travel/15.23 Japan"}, "15.23 Japan.pdf", "10-19 Life/15 Travel/15.23 Japan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename+" in "+tt.existing[0], func(t *testing.T) {
+			root := t.TempDir()
+			for _, dir := range tt.existing {
+				if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			segments := tt.scheme.Parse(tt.filename)
+			if segments == nil {
+				t.Fatalf("%s not recognised", tt.filename)
+			}
+			want := filepath.Join(root, filepath.FromSlash(tt.want))
+			if found := FindFolders(tt.scheme, root, segments); found != want {
+				t.Errorf("found %s, want %s", found, want)
+			}
+			if _, err := os.Stat(want); tt.want != tt.existing[0] && !os.IsNotExist(err) {
+				t.Errorf("FindFolders created %s", want)
+			}
+
+			got, err := EnsureFolders(tt.scheme, root, segments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("filed in %s, want %s", got, want)
+			}
+		})
+	}
+}
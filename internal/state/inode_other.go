@@ -0,0 +1,10 @@
+//go:build !unix
+
+package state
+
+import "io/fs"
+
+// inode returns 0: the platform has no inode numbers to compare.
+func inode(info fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package state
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// inode returns the inode number of the file described by info.
+func inode(info fs.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
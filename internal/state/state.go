@@ -0,0 +1,448 @@
+package state
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DBFilename is the state database in the state directory.
+const DBFilename = "state.db"
+
+// openTimeout is how long to wait for another daemon holding the database.
+const openTimeout = time.Second
+
+// flushEvery is how many recorded changes are written to the database at once.
+const flushEvery = 1000
+
+// racyWindow is how recently a directory may have changed and still be
+// trusted to show its next change in its modification time. Filesystems with
+// coarse timestamps could otherwise hide a change made in the same tick.
+const racyWindow = 2 * time.Second
+
+// format is the layout of the database. It is part of the fingerprint, so
+// that a database written with another layout is emptied when opened.
+const format = "2"
+
+var (
+	filesBucket = []byte("files") // Directory, NUL, filename -> Entry
+	dirsBucket  = []byte("dirs")  // Directory -> Dir
+	metaBucket  = []byte("meta")
+	keyConfig   = []byte("config")
+	keyDirs     = []byte("dirs")
+)
+
+// Entry is what is known about a file from the last scan that saw it.
+type Entry struct {
+	Size      int64
+	ModTime   time.Time
+	Inode     uint64 // 0 where the platform has no inode numbers
+	Compliant bool   // Whether the file needed nothing done: it was where it belongs, or is left where it is
+}
+
+// Unchanged reports whether the file described by info is as the last scan
+// left it: same size, modification time and inode, and compliant.
+func (e Entry) Unchanged(info fs.FileInfo) bool {
+	cur := entryOf(info, e.Compliant)
+	return e.Compliant && cur.Size == e.Size && cur.ModTime.Equal(e.ModTime) && cur.Inode == e.Inode
+}
+
+// Dir is what is known about a directory from the last scan that listed it,
+// recorded only if every file in it was compliant.
+type Dir struct {
+	ModTime time.Time
+	Inode   uint64
+	Files   int      // Files directly in it
+	Subdirs []string // Names of its subdirectories
+}
+
+// Listing is what a scan found in a directory it listed.
+type Listing struct {
+	Info      fs.FileInfo // Of the directory, taken before it was listed
+	Files     int         // Files directly in it
+	Subdirs   []string    // Names of its subdirectories
+	Gone      []string    // Names of files recorded in it that are no longer there
+	Compliant bool        // Whether every file in it was compliant
+}
+
+// DB is the state database of a root: the directories and files seen by the
+// last scan, so that the next one only processes what changed. It is safe
+// for concurrent use.
+type DB struct {
+	mu        sync.Mutex // Guards pending, dirs, forgotten and seen
+	db        *bolt.DB
+	pending   map[string]Entry    // Files recorded but not yet written, by key
+	dirs      map[string]*Dir     // Directories recorded but not yet written; nil to forget one
+	forgotten [][]byte            // Keys of files to forget
+	seen      map[uint64]struct{} // Hashes of the directories seen by this scan
+}
+
+// Open opens the state database under root, creating it if needed.
+func Open(root string) (*DB, error) {
+	if err := os.MkdirAll(filepath.Join(root, config.StateDir), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(filepath.Join(root, config.StateDir, DBFilename), 0644, &bolt.Options{Timeout: openTimeout})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("state database is in use by another jdd")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{filesBucket, dirsBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	d := &DB{db: db}
+	d.clear()
+	return d, nil
+}
+
+// Close writes out pending records and closes the database.
+func (d *DB) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.flush(); err != nil {
+		d.db.Close()
+		return err
+	}
+	return d.db.Close()
+}
+
+// Config returns the configuration fingerprint stored by the last scan.
+func (d *DB) Config() (string, error) {
+	var fp string
+	err := d.db.View(func(tx *bolt.Tx) error {
+		fp = string(tx.Bucket(metaBucket).Get(keyConfig))
+		return nil
+	})
+	return fp, err
+}
+
+// Reset forgets every directory and file, so that the next scan processes
+// them all, and stores a new configuration fingerprint.
+func (d *DB) Reset(fingerprint string) error {
+	d.mu.Lock()
+	d.clear()
+	d.mu.Unlock()
+
+	return d.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{filesBucket, dirsBucket} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(metaBucket).Put(keyConfig, []byte(fingerprint))
+	})
+}
+
+// UnchangedDir returns what the last scan recorded about the directory at
+// path if it is as that scan left it: same modification time and inode, so
+// holding the same names, every file in it compliant. Its files need not be
+// looked at again; its subdirectories must, as changes inside them do not
+// show on it. Returns nil if the directory must be listed. An unchanged
+// directory is marked as seen by this scan.
+func (d *DB) UnchangedDir(path string, info fs.FileInfo) (*Dir, error) {
+	var old *Dir
+	err := d.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(dirsBucket).Get([]byte(path)); data != nil {
+			old = decodeDir(data)
+		}
+		return nil
+	})
+	if err != nil || old == nil {
+		return nil, err
+	}
+	if !old.ModTime.Equal(info.ModTime()) || old.Inode != inode(info) {
+		return nil, nil
+	}
+
+	d.mu.Lock()
+	d.seen[hashPath(path)] = struct{}{}
+	d.mu.Unlock()
+	return old, nil
+}
+
+// Files returns what the last scan recorded about the files directly in the
+// directory at path, by name.
+func (d *DB) Files(path string) (map[string]Entry, error) {
+	files := make(map[string]Entry)
+	prefix := []byte(path + "\x00")
+	err := d.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(filesBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			files[string(k[len(prefix):])] = decode(v)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// Record remembers the file at path after it was processed. Files that are no
+// longer there, having been moved, are skipped.
+func (d *DB) Record(path string, compliant bool) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending[fileKey(path)] = entryOf(info, compliant)
+	return d.flushIfFull()
+}
+
+// RecordDir remembers the directory at path after its files were processed,
+// forgets the files no longer in it and marks it as seen by this scan. It is
+// only remembered as a whole if every file in it was compliant and it did not
+// change too recently to be sure its next change will show.
+func (d *DB) RecordDir(path string, l Listing) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.seen[hashPath(path)] = struct{}{}
+	for _, name := range l.Gone {
+		d.forgotten = append(d.forgotten, []byte(fileKey(filepath.Join(path, name))))
+	}
+	d.dirs[path] = nil
+	if l.Compliant && time.Since(l.Info.ModTime()) >= racyWindow {
+		d.dirs[path] = &Dir{ModTime: l.Info.ModTime(), Inode: inode(l.Info), Files: l.Files, Subdirs: l.Subdirs}
+	}
+	return d.flushIfFull()
+}
+
+// Finish ends a scan that visited dirs directories: pending records are
+// written, and directories the scan did not see, with the files in them,
+// are forgotten.
+func (d *DB) Finish(dirs int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.flush(); err != nil {
+		return err
+	}
+
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{filesBucket, dirsBucket} {
+			b := tx.Bucket(name)
+			var stale [][]byte
+			err := b.ForEach(func(k, v []byte) error {
+				dir := k
+				if i := bytes.IndexByte(k, 0); i >= 0 {
+					dir = k[:i]
+				}
+				if _, ok := d.seen[hashPath(string(dir))]; !ok {
+					stale = append(stale, bytes.Clone(k))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, k := range stale {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Bucket(metaBucket).Put(keyDirs, binary.BigEndian.AppendUint64(nil, uint64(dirs)))
+	})
+	d.seen = make(map[uint64]struct{})
+	return err
+}
+
+// Dirs returns the number of directories the last scan visited, or 0 if unknown.
+func (d *DB) Dirs() (int64, error) {
+	var dirs int64
+	err := d.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(metaBucket).Get(keyDirs); len(data) == 8 {
+			dirs = int64(binary.BigEndian.Uint64(data))
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// clear drops everything pending and seen. The caller holds mu, or has the
+// only reference to d.
+func (d *DB) clear() {
+	d.pending = make(map[string]Entry)
+	d.dirs = make(map[string]*Dir)
+	d.forgotten = nil
+	d.seen = make(map[uint64]struct{})
+}
+
+// flushIfFull flushes once enough changes are pending. The caller holds mu.
+func (d *DB) flushIfFull() error {
+	if len(d.pending)+len(d.dirs)+len(d.forgotten) >= flushEvery {
+		return d.flush()
+	}
+	return nil
+}
+
+// flush writes the pending changes in one transaction. The caller holds mu.
+func (d *DB) flush() error {
+	if len(d.pending)+len(d.dirs)+len(d.forgotten) == 0 {
+		return nil
+	}
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		files := tx.Bucket(filesBucket)
+		for _, k := range d.forgotten {
+			if err := files.Delete(k); err != nil {
+				return err
+			}
+		}
+		for k, e := range d.pending {
+			if err := files.Put([]byte(k), encode(e)); err != nil {
+				return err
+			}
+		}
+		dirs := tx.Bucket(dirsBucket)
+		for path, dir := range d.dirs {
+			var err error
+			if dir == nil {
+				err = dirs.Delete([]byte(path))
+			} else {
+				err = dirs.Put([]byte(path), encodeDir(dir))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	d.pending = make(map[string]Entry)
+	d.dirs = make(map[string]*Dir)
+	d.forgotten = nil
+	return err
+}
+
+// fileKey returns the key of the file at path: its directory and name,
+// separated by a NUL so that the files of one directory sort together.
+func fileKey(path string) string {
+	return filepath.Dir(path) + "\x00" + filepath.Base(path)
+}
+
+// entryOf returns the entry for a file described by info.
+func entryOf(info fs.FileInfo, compliant bool) Entry {
+	return Entry{Size: info.Size(), ModTime: info.ModTime(), Inode: inode(info), Compliant: compliant}
+}
+
+// hashPath returns a short stand-in for path, to track millions of seen paths cheaply.
+func hashPath(path string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	return h.Sum64()
+}
+
+// encode packs an entry into 25 bytes: size, modification time in
+// nanoseconds and inode, then a compliance flag.
+func encode(e Entry) []byte {
+	data := make([]byte, 25)
+	binary.BigEndian.PutUint64(data[0:], uint64(e.Size))
+	binary.BigEndian.PutUint64(data[8:], uint64(e.ModTime.UnixNano()))
+	binary.BigEndian.PutUint64(data[16:], e.Inode)
+	if e.Compliant {
+		data[24] = 1
+	}
+	return data
+}
+
+// decode unpacks an entry written by encode. Malformed data decodes to an
+// entry that never matches a file, so the file is processed again.
+func decode(data []byte) Entry {
+	if len(data) != 25 {
+		return Entry{Size: -1}
+	}
+	return Entry{
+		Size:      int64(binary.BigEndian.Uint64(data[0:])),
+		ModTime:   time.Unix(0, int64(binary.BigEndian.Uint64(data[8:]))),
+		Inode:     binary.BigEndian.Uint64(data[16:]),
+		Compliant: data[24] == 1,
+	}
+}
+
+// encodeDir packs a directory into its modification time in nanoseconds,
+// inode and number of files, followed by its subdirectory names, each ended
+// by a NUL.
+func encodeDir(dir *Dir) []byte {
+	data := make([]byte, 24)
+	binary.BigEndian.PutUint64(data[0:], uint64(dir.ModTime.UnixNano()))
+	binary.BigEndian.PutUint64(data[8:], dir.Inode)
+	binary.BigEndian.PutUint64(data[16:], uint64(dir.Files))
+	for _, name := range dir.Subdirs {
+		data = append(append(data, name...), 0)
+	}
+	return data
+}
+
+// decodeDir unpacks a directory written by encodeDir, or returns nil for
+// malformed data, so that the directory is listed again.
+func decodeDir(data []byte) *Dir {
+	if len(data) < 24 || (len(data) > 24 && data[len(data)-1] != 0) {
+		return nil
+	}
+	dir := &Dir{
+		ModTime: time.Unix(0, int64(binary.BigEndian.Uint64(data[0:]))),
+		Inode:   binary.BigEndian.Uint64(data[8:]),
+		Files:   int(binary.BigEndian.Uint64(data[16:])),
+	}
+	for rest := data[24:]; len(rest) > 0; {
+		i := bytes.IndexByte(rest, 0)
+		dir.Subdirs = append(dir.Subdirs, string(rest[:i]))
+		rest = rest[i+1:]
+	}
+	return dir
+}
+
+// Fingerprint summarises the settings that decide where files belong. When it
+// changes, files the last scan found compliant may no longer be.
+func Fingerprint(cfg *config.Config) (string, error) {
+	c := *cfg
+	// Settings that do not affect filing
+	c.LogLevel, c.Daemonize, c.Delay, c.Notifications, c.FullRescan = "", false, 0, false, false
+	c.RetryAttempts, c.RetryBackoff, c.ScanWorkers, c.BrakeMoves, c.BrakeBurst = 0, 0, 0, 0, 0
+	c.Force, c.ConfirmThreshold, c.Preflighted = false, 0, false
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(append([]byte(format), data...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Exists reports whether root has a state database, that is, whether a daemon
+// has scanned it before.
+func Exists(root string) bool {
+	_, err := os.Stat(filepath.Join(root, config.StateDir, DBFilename))
+	return err == nil
+}
@@ -0,0 +1,293 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+)
+
+// open opens the state database of a new root, closed when the test ends.
+func open(t *testing.T) (*DB, string) {
+	t.Helper()
+	root := t.TempDir()
+	d, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d, root
+}
+
+// write creates the file at path, and its folders, with content.
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// settle backdates dir past racyWindow and returns its info.
+func settle(t *testing.T, dir string) os.FileInfo {
+	t.Helper()
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(dir, old, old); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestRecord(t *testing.T) {
+	d, root := open(t)
+	dir := filepath.Join(root, "15 Travel")
+	ticket := filepath.Join(dir, "15.23 ticket.pdf")
+	notes := filepath.Join(dir, "notes.txt")
+	nested := filepath.Join(dir, "15.23 Japan", "15.23 visa.pdf")
+	for _, path := range []string{ticket, notes, nested} {
+		write(t, path, "v1")
+	}
+
+	if err := d.Record(ticket, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Record(notes, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Record(nested, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Record(filepath.Join(dir, "moved.pdf"), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Finish(0); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing was seen, so nothing is kept
+	if files, err := d.Files(dir); err != nil || len(files) != 0 {
+		t.Fatalf("got %v, %v after a scan that saw nothing", files, err)
+	}
+
+	for _, path := range []string{ticket, notes} {
+		if err := d.Record(path, path == ticket); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Record(nested, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RecordDir(dir, Listing{Info: settle(t, dir)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Finish(1); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := d.Files(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %v, want the two files directly in %s", files, dir)
+	}
+	info, err := os.Stat(ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !files["15.23 ticket.pdf"].Unchanged(info) {
+		t.Error("compliant file reported changed")
+	}
+	info, err = os.Stat(notes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files["notes.txt"].Unchanged(info) {
+		t.Error("file that was not compliant reported unchanged")
+	}
+
+	write(t, ticket, "version 2")
+	info, err = os.Stat(ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files["15.23 ticket.pdf"].Unchanged(info) {
+		t.Error("rewritten file reported unchanged")
+	}
+}
+
+func TestUnchangedDir(t *testing.T) {
+	tests := []struct {
+		name      string
+		compliant bool
+		racy      bool // Changed just before it was listed
+		change    bool // Changed after it was listed
+		want      bool
+	}{
+		{"unchanged", true, false, false, true},
+		{"not compliant", false, false, false, false},
+		{"racy", true, true, false, false},
+		{"changed", true, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, root := open(t)
+			dir := filepath.Join(root, "15 Travel")
+			write(t, filepath.Join(dir, "15.23 Japan", "15.23 visa.pdf"), "")
+			write(t, filepath.Join(dir, "15.23 ticket.pdf"), "")
+
+			info := settle(t, dir)
+			if tt.racy {
+				write(t, filepath.Join(dir, "15.24 new.pdf"), "")
+				var err error
+				if info, err = os.Stat(dir); err != nil {
+					t.Fatal(err)
+				}
+			}
+			l := Listing{Info: info, Files: 1, Subdirs: []string{"15.23 Japan"}, Compliant: tt.compliant}
+			if err := d.RecordDir(dir, l); err != nil {
+				t.Fatal(err)
+			}
+			if err := d.Finish(1); err != nil {
+				t.Fatal(err)
+			}
+			if tt.change {
+				write(t, filepath.Join(dir, "15.24 new.pdf"), "")
+			}
+
+			info, err := os.Stat(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := d.UnchangedDir(dir, info)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got != nil) != tt.want {
+				t.Fatalf("got %+v, want unchanged %v", got, tt.want)
+			}
+			if got != nil && (got.Files != 1 || !reflect.DeepEqual(got.Subdirs, l.Subdirs)) {
+				t.Errorf("got %+v, want %+v", got, l)
+			}
+		})
+	}
+}
+
+// TestFinish forgets directories the scan did not see, with their files,
+// and files gone from a directory it listed.
+func TestFinish(t *testing.T) {
+	d, root := open(t)
+	kept := filepath.Join(root, "15 Travel")
+	gone := filepath.Join(root, "16 Home")
+	for _, path := range []string{
+		filepath.Join(kept, "15.23 ticket.pdf"),
+		filepath.Join(kept, "15.24 visa.pdf"),
+		filepath.Join(gone, "16.01 lease.pdf"),
+	} {
+		write(t, path, "")
+		if err := d.Record(path, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, dir := range []string{kept, gone} {
+		if err := d.RecordDir(dir, Listing{Info: settle(t, dir), Files: 2, Compliant: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Finish(2); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second scan finds one file gone and does not see the other folder
+	if err := d.RecordDir(kept, Listing{Info: settle(t, kept), Files: 1, Gone: []string{"15.24 visa.pdf"}, Compliant: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Finish(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if files, err := d.Files(kept); err != nil || len(files) != 1 {
+		t.Errorf("got %v, %v, want only the ticket", files, err)
+	}
+	if files, err := d.Files(gone); err != nil || len(files) != 0 {
+		t.Errorf("got %v, %v for a folder not seen", files, err)
+	}
+	if dir, err := d.UnchangedDir(gone, settle(t, gone)); err != nil || dir != nil {
+		t.Errorf("got %+v, %v for a folder not seen", dir, err)
+	}
+	if dirs, err := d.Dirs(); err != nil || dirs != 1 {
+		t.Errorf("got %d, %v directories, want 1", dirs, err)
+	}
+}
+
+func TestReset(t *testing.T) {
+	d, root := open(t)
+	dir := filepath.Join(root, "15 Travel")
+	write(t, filepath.Join(dir, "15.23 ticket.pdf"), "")
+	if err := d.Record(filepath.Join(dir, "15.23 ticket.pdf"), true); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.RecordDir(dir, Listing{Info: settle(t, dir), Files: 1, Compliant: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Finish(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Reset("new"); err != nil {
+		t.Fatal(err)
+	}
+	if fp, err := d.Config(); err != nil || fp != "new" {
+		t.Errorf("got fingerprint %q, %v", fp, err)
+	}
+	if files, err := d.Files(dir); err != nil || len(files) != 0 {
+		t.Errorf("got %v, %v after a reset", files, err)
+	}
+	if got, err := d.UnchangedDir(dir, settle(t, dir)); err != nil || got != nil {
+		t.Errorf("got %+v, %v after a reset", got, err)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	fingerprint := func(cfg config.Config) string {
+		t.Helper()
+		fp, err := Fingerprint(&cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fp
+	}
+
+	base := fingerprint(config.Config{Root: "~/Documents"})
+	if fp := fingerprint(config.Config{Root: "~/Documents", LogLevel: "debug", ScanWorkers: 4, FullRescan: true, Preflighted: true}); fp != base {
+		t.Error("settings that do not affect filing changed the fingerprint")
+	}
+	if fp := fingerprint(config.Config{Root: "~/Documents", Patterns: []string{"bracket"}}); fp == base {
+		t.Error("patterns did not change the fingerprint")
+	}
+}
+
+func TestEncodeDir(t *testing.T) {
+	for _, dir := range []*Dir{
+		{ModTime: time.Unix(0, 1700000000123456789), Inode: 42, Files: 3, Subdirs: []string{"15.23 Japan", "Notes"}},
+		{ModTime: time.Unix(0, 1), Files: 0},
+	} {
+		got := decodeDir(encodeDir(dir))
+		if got == nil || !got.ModTime.Equal(dir.ModTime) || got.Inode != dir.Inode || got.Files != dir.Files || !reflect.DeepEqual(got.Subdirs, dir.Subdirs) {
+			t.Errorf("got %+v, want %+v", got, dir)
+		}
+	}
+	for _, data := range [][]byte{nil, make([]byte, 10), append(make([]byte, 24), "Japan"...)} {
+		if got := decodeDir(data); got != nil {
+			t.Errorf("decoded %q to %+v", data, got)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package suggest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/config"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+)
+
+// ReviewFilename is the queue of files waiting for review in the state
+// directory: files the daemon could not file with enough confidence.
+const ReviewFilename = "review.json"
+
+// Review is a queued file and the suggestions made for it.
+type Review struct {
+	Path        string       `json:"path"`
+	Queued      time.Time    `json:"queued"`
+	Suggestions []Suggestion `json:"suggestions"`
+}
+
+// reviewPath returns the review queue file for root.
+func reviewPath(root string) string {
+	return filepath.Join(root, config.StateDir, ReviewFilename)
+}
+
+// Queue adds a file to the review queue under root, replacing any earlier
+// entry for the same path.
+func Queue(root string, r Review) error {
+	reviews, err := readReviews(root)
+	if err != nil {
+		return err
+	}
+	reviews[r.Path] = r
+	return writeReviews(root, reviews)
+}
+
+// Pending returns the queued files that are still where they were queued,
+// oldest first, and drops the rest from the queue.
+func Pending(root string) ([]Review, error) {
+	reviews, err := readReviews(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Review
+	changed := false
+	for path, r := range reviews {
+		if _, err := os.Stat(path); err != nil {
+			delete(reviews, path)
+			changed = true
+			continue
+		}
+		pending = append(pending, r)
+	}
+	if changed {
+		if err := writeReviews(root, reviews); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Queued.Before(pending[j].Queued) })
+	return pending, nil
+}
+
+// readReviews reads the review queue, by path.
+func readReviews(root string) (map[string]Review, error) {
+	reviews := make(map[string]Review)
+	data, err := os.ReadFile(reviewPath(root))
+	if os.IsNotExist(err) {
+		return reviews, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &reviews); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// writeReviews replaces the review queue.
+func writeReviews(root string, reviews map[string]Review) error {
+	if err := os.MkdirAll(filepath.Join(root, config.StateDir), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(reviews, "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(reviewPath(root), data, 0644)
+}
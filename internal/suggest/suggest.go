@@ -0,0 +1,224 @@
+package suggest
+
+import (
+	"io/fs"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+)
+
+// DefaultThreshold is the confidence needed to file by suggestion when none is configured.
+const DefaultThreshold = 0.8
+
+// Suggestion is a likely ID for a file.
+type Suggestion struct {
+	ID         string  `json:"id"`
+	Folder     string  `json:"folder"`
+	Confidence float64 `json:"confidence"` // Between 0 and 1; the suggestions for a file sum to at most 1
+}
+
+// Model is a naive Bayes classifier over filename tokens, trained on the
+// files already filed under a root. It is safe for concurrent use, so that
+// files can be learned as they are filed.
+type Model struct {
+	mu      sync.RWMutex
+	docs    map[string]int            // ID -> files seen
+	counts  map[string]map[string]int // ID -> token -> occurrences
+	totals  map[string]int            // ID -> tokens seen
+	folders map[string]string         // ID -> folder path
+	vocab   map[string]bool
+	total   int // Files seen
+}
+
+// Train builds a model from every file inside an ID folder under root.
+// Hidden files and folders are skipped.
+func Train(root string) (*Model, error) {
+	m := &Model{
+		docs:    make(map[string]int),
+		counts:  make(map[string]map[string]int),
+		totals:  make(map[string]int),
+		folders: make(map[string]string),
+		vocab:   make(map[string]bool),
+	}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			m.Learn(root, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Learn adds the file at path, if it is inside an ID folder under root, to the model.
+func (m *Model) Learn(root, path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+
+	// The innermost ID folder is the label; folders below it are features
+	for i := len(parts) - 2; i >= 0; i-- {
+		id := jd.IDFolderPattern.FindStringSubmatch(parts[i])
+		if id == nil {
+			continue
+		}
+		label := id[1] + "." + id[2]
+		m.folders[label] = filepath.Join(append([]string{root}, parts[:i+1]...)...)
+
+		var tokens []string
+		for _, sub := range parts[i+1 : len(parts)-1] {
+			tokens = append(tokens, Tokens(sub)...)
+		}
+		tokens = append(tokens, Tokens(parts[len(parts)-1])...)
+		m.add(label, tokens)
+		return
+	}
+}
+
+// add counts a file with the given tokens under label.
+func (m *Model) add(label string, tokens []string) {
+	if m.counts[label] == nil {
+		m.counts[label] = make(map[string]int)
+	}
+	m.docs[label]++
+	m.total++
+	for _, t := range tokens {
+		m.counts[label][t]++
+		m.totals[label]++
+		m.vocab[t] = true
+	}
+}
+
+// Suggest returns up to n likely IDs for a file, most likely first. The
+// filename and the name of the folder it is in are used. Nothing is suggested
+// by a model that has seen no files, or for a file sharing no words with the
+// files seen, as the extension alone says too little.
+func (m *Model) Suggest(path string, n int) []Suggestion {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.total == 0 {
+		return nil
+	}
+
+	tokens := Tokens(filepath.Base(path))
+	if dir := filepath.Base(filepath.Dir(path)); dir != "." && dir != string(filepath.Separator) {
+		tokens = append(tokens, Tokens(dir)...)
+	}
+	if !m.knowsWord(tokens) {
+		return nil
+	}
+
+	// Log-probabilities with add-one smoothing; tokens never seen in
+	// training carry no information and are left out
+	scores := make(map[string]float64, len(m.docs))
+	vocab := float64(len(m.vocab))
+	for label, docs := range m.docs {
+		score := math.Log(float64(docs) / float64(m.total))
+		for _, t := range tokens {
+			if m.vocab[t] {
+				score += math.Log((float64(m.counts[label][t]) + 1) / (float64(m.totals[label]) + vocab))
+			}
+		}
+		scores[label] = score
+	}
+
+	// Normalise into posterior probabilities
+	best := math.Inf(-1)
+	for _, s := range scores {
+		best = math.Max(best, s)
+	}
+	sum := 0.0
+	for _, s := range scores {
+		sum += math.Exp(s - best)
+	}
+
+	suggestions := make([]Suggestion, 0, len(scores))
+	for label, s := range scores {
+		suggestions = append(suggestions, Suggestion{
+			ID:         label,
+			Folder:     m.folders[label],
+			Confidence: math.Exp(s-best) / sum,
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence != suggestions[j].Confidence {
+			return suggestions[i].Confidence > suggestions[j].Confidence
+		}
+		return suggestions[i].ID < suggestions[j].ID
+	})
+	if len(suggestions) > n {
+		suggestions = suggestions[:n]
+	}
+	return suggestions
+}
+
+// knowsWord reports whether any of tokens, other than an extension, was
+// seen in training.
+func (m *Model) knowsWord(tokens []string) bool {
+	for _, t := range tokens {
+		if m.vocab[t] && !strings.HasPrefix(t, "ext:") {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixPattern matches an ID or placeholder prefix, which says nothing about the content.
+var prefixPattern = regexp.MustCompile(`^(?:[A-Z]\d{2}\.)?\d{2}\.(?:\d{2}|xx|XX|\?\?)(?:\+\S+)?`)
+
+// Tokens splits a file or folder name into lower-case words, dropping any ID
+// prefix and short numbers. The extension becomes a token of its own, "ext:pdf".
+func Tokens(name string) []string {
+	name = prefixPattern.ReplaceAllString(name, "")
+
+	var tokens []string
+	if ext := filepath.Ext(name); ext != "" && len(ext) <= 6 {
+		tokens = append(tokens, "ext:"+strings.ToLower(ext[1:]))
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if len(w) < 2 || (isNumber(w) && len(w) < 4) {
+			continue
+		}
+		tokens = append(tokens, w)
+	}
+	return tokens
+}
+
+// isNumber reports whether s is made only of digits.
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package suggest
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestLearn files a file after training and checks that it is suggested
+// from then on.
+func TestLearn(t *testing.T) {
+	root := t.TempDir()
+	japan := filepath.Join(root, "10-19 Life", "15 Travel", "15.23 Japan")
+	rent := filepath.Join(root, "10-19 Life", "16 Home", "16.01 Rent")
+	for _, path := range []string{
+		filepath.Join(japan, "15.23 flight ticket.pdf"),
+		filepath.Join(rent, "16.01 lease.pdf"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := Train(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Suggest(filepath.Join(root, "ryokan booking.pdf"), 1); len(got) != 0 {
+		t.Fatalf("suggested %+v for unknown words", got)
+	}
+
+	// Learned concurrently with suggesting; run with -race
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.Learn(root, filepath.Join(japan, "15.23 ryokan booking.pdf"))
+		}()
+		go func() {
+			defer wg.Done()
+			m.Suggest(filepath.Join(root, "lease renewal.pdf"), 1)
+		}()
+	}
+	wg.Wait()
+
+	got := m.Suggest(filepath.Join(root, "ryokan booking.pdf"), 1)
+	if len(got) != 1 || got[0].ID != "15.23" || got[0].Folder != japan {
+		t.Fatalf("got %+v, want 15.23 in %s", got, japan)
+	}
+}
@@ -0,0 +1,23 @@
+// Package testutil holds helpers shared by the tests of several packages.
+package testutil
+
+import (
+	"os"
+	"testing"
+)
+
+// AssertEntries fails the test unless dir holds exactly n entries.
+func AssertEntries(t testing.TB, dir string, n int) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != n {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Fatalf("%s holds %v, want %d entries", dir, names, n)
+	}
+}
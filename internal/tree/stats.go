@@ -0,0 +1,102 @@
+package tree
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Stats summarizes the files filed under an ID folder.
+type Stats struct {
+	Files    int       `json:"files" yaml:"files"`       // Number of regular files, recursively
+	Size     int64     `json:"size" yaml:"size"`         // Total size in bytes
+	Modified time.Time `json:"modified" yaml:"modified"` // Latest modification time of any file
+}
+
+// LoadStats computes Stats for every ID in the tree.
+func (t *Tree) LoadStats() error {
+	for _, area := range t.Areas {
+		for _, category := range area.Categories {
+			for _, id := range category.IDs {
+				stats, err := statDir(id.Path)
+				if err != nil {
+					return err
+				}
+				id.Stats = stats
+			}
+		}
+	}
+	return nil
+}
+
+// Filter returns a copy of the tree containing only the area ("10-19"),
+// category ("15") or ID ("15.23") with the given number.
+func (t *Tree) Filter(number string) (*Tree, error) {
+	filtered := &Tree{Root: t.Root}
+
+	for _, area := range t.Areas {
+		if area.Number == number {
+			filtered.Areas = append(filtered.Areas, area)
+			continue
+		}
+
+		var categories []*Category
+		for _, category := range area.Categories {
+			if category.Number == number {
+				categories = append(categories, category)
+				continue
+			}
+			for _, id := range category.IDs {
+				if id.Number == number {
+					c := *category
+					c.IDs = []*ID{id}
+					categories = append(categories, &c)
+				}
+			}
+		}
+
+		if len(categories) > 0 {
+			a := *area
+			a.Categories = categories
+			filtered.Areas = append(filtered.Areas, &a)
+		}
+	}
+
+	if len(filtered.Areas) == 0 {
+		return nil, fmt.Errorf("no area, category or ID %s under %s", number, t.Root)
+	}
+	return filtered, nil
+}
+
+// statDir walks dir and sums up the regular files in it, skipping hidden entries.
+func statDir(dir string) (*Stats, error) {
+	stats := &Stats{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stats.Files++
+		stats.Size += info.Size()
+		if info.ModTime().After(stats.Modified) {
+			stats.Modified = info.ModTime()
+		}
+		return nil
+	})
+	return stats, err
+}
@@ -0,0 +1,159 @@
+package tree
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+)
+
+// Tree is the Johnny Decimal hierarchy found under a root directory.
+type Tree struct {
+	Root  string  `json:"root,omitempty" yaml:"root,omitempty"`
+	Areas []*Area `json:"areas" yaml:"areas"`
+}
+
+// Area is an area folder, e.g. "10-19 Life admin".
+type Area struct {
+	Number     string      `json:"number" yaml:"number"` // e.g. "10-19"
+	Name       string      `json:"name,omitempty" yaml:"name,omitempty"`
+	Path       string      `json:"path,omitempty" yaml:"path,omitempty"`
+	Categories []*Category `json:"categories,omitempty" yaml:"categories,omitempty"`
+}
+
+// Category is a category folder, e.g. "15 Travel".
+type Category struct {
+	Number string `json:"number" yaml:"number"` // e.g. "15"
+	Name   string `json:"name,omitempty" yaml:"name,omitempty"`
+	Path   string `json:"path,omitempty" yaml:"path,omitempty"`
+	IDs    []*ID  `json:"ids,omitempty" yaml:"ids,omitempty"`
+}
+
+// ID is an ID folder, e.g. "15.23 Japan".
+type ID struct {
+	Number string `json:"number" yaml:"number"` // e.g. "15.23"
+	Name   string `json:"name,omitempty" yaml:"name,omitempty"`
+	Path   string `json:"path,omitempty" yaml:"path,omitempty"`
+	Stats  *Stats `json:"stats,omitempty" yaml:"stats,omitempty"` // Only set by LoadStats
+}
+
+// Load walks root and returns the area, category and ID folders it contains.
+// Folders that do not follow the Johnny Decimal naming scheme are ignored.
+func Load(root string) (*Tree, error) {
+	t := &Tree{Root: root}
+
+	areaDirs, err := subdirs(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, areaDir := range areaDirs {
+		m := jd.AreaFolderPattern.FindStringSubmatch(areaDir)
+		if m == nil {
+			continue
+		}
+		area := &Area{Number: m[1] + "-" + m[2], Name: m[3], Path: filepath.Join(root, areaDir)}
+
+		categoryDirs, err := subdirs(area.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, categoryDir := range categoryDirs {
+			m := jd.CategoryFolderPattern.FindStringSubmatch(categoryDir)
+			if m == nil {
+				continue
+			}
+			category := &Category{Number: m[1], Name: m[2], Path: filepath.Join(area.Path, categoryDir)}
+
+			idDirs, err := subdirs(category.Path)
+			if err != nil {
+				return nil, err
+			}
+			for _, idDir := range idDirs {
+				m := jd.IDFolderPattern.FindStringSubmatch(idDir)
+				if m == nil {
+					continue
+				}
+				category.IDs = append(category.IDs, &ID{
+					Number: m[1] + "." + m[2],
+					Name:   m[3],
+					Path:   filepath.Join(category.Path, idDir),
+				})
+			}
+
+			area.Categories = append(area.Categories, category)
+		}
+
+		t.Areas = append(t.Areas, area)
+	}
+
+	return t, nil
+}
+
+// Area returns the area with the given number, e.g. "10-19", or nil.
+func (t *Tree) Area(number string) *Area {
+	for _, area := range t.Areas {
+		if area.Number == number {
+			return area
+		}
+	}
+	return nil
+}
+
+// Category returns the category with the given number, e.g. "15", or nil.
+// It is safe to call on a nil *Area.
+func (a *Area) Category(number string) *Category {
+	if a == nil {
+		return nil
+	}
+	for _, category := range a.Categories {
+		if category.Number == number {
+			return category
+		}
+	}
+	return nil
+}
+
+// ID returns the ID with the given number, e.g. "15.23", or nil.
+// It is safe to call on a nil *Category.
+func (c *Category) ID(number string) *ID {
+	if c == nil {
+		return nil
+	}
+	for _, id := range c.IDs {
+		if id.Number == number {
+			return id
+		}
+	}
+	return nil
+}
+
+// Label returns the area's number and name, e.g. "10-19 Life admin".
+func (a *Area) Label() string { return label(a.Number, a.Name) }
+
+// Label returns the category's number and name, e.g. "15 Travel".
+func (c *Category) Label() string { return label(c.Number, c.Name) }
+
+// Label returns the ID's number and name, e.g. "15.23 Japan".
+func (id *ID) Label() string { return label(id.Number, id.Name) }
+
+// label joins a number and an optional name with a space.
+func label(number, name string) string {
+	return strings.TrimSpace(number + " " + name)
+}
+
+// subdirs returns the names of the non-hidden directories in dir, sorted by name.
+func subdirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
@@ -2,9 +2,14 @@ package utils
 
 import (
 	_ "embed"
+	"errors"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"syscall"
 
 	"github.com/gen2brain/beeep"
 	log "github.com/sirupsen/logrus"
@@ -30,3 +35,78 @@ func SendNotification(enabled bool, title string, message string) {
 		}
 	}
 }
+
+// OpenPath opens a file or folder with the platform's default application.
+func OpenPath(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", path)
+	case "windows":
+		cmd = exec.Command("explorer", path)
+	default:
+		cmd = exec.Command("xdg-open", path)
+	}
+	return cmd.Start()
+}
+
+// MoveFile renames oldPath to newPath. When the two are on different
+// filesystems, it copies the file and removes the original instead.
+func MoveFile(oldPath, newPath string) error {
+	err := os.Rename(oldPath, newPath)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	src, err := os.Open(oldPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(newPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(newPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(newPath)
+		return err
+	}
+
+	_ = os.Chtimes(newPath, info.ModTime(), info.ModTime())
+	return os.Remove(oldPath)
+}
+
+// WriteFileAtomic replaces the file at path with data through a temporary
+// file in the same folder, so that readers see either the old or the new
+// contents and never half of them.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
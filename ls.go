@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mahyarmirrashed/jdd/internal/tree"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	"github.com/urfave/cli/v3"
+)
+
+// lsColumns selects the optional per-ID columns of the ls command.
+type lsColumns struct {
+	files    bool
+	size     bool
+	modified bool
+}
+
+// any reports whether any optional column is enabled.
+func (c lsColumns) any() bool {
+	return c.files || c.size || c.modified
+}
+
+// lsCommand lists the Johnny Decimal hierarchy under root.
+func lsCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "ls",
+		Usage:     "list the areas, categories and IDs under root as a tree",
+		ArgsUsage: "[AREA|CATEGORY|ID]",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "format",
+				Aliases: []string{"f"},
+				Usage:   "output format: text, json, csv",
+				Value:   "text",
+			},
+			&cli.BoolFlag{
+				Name:  "files",
+				Usage: "show the number of files in each ID",
+			},
+			&cli.BoolFlag{
+				Name:  "size",
+				Usage: "show the total size of each ID",
+			},
+			&cli.BoolFlag{
+				Name:  "modified",
+				Usage: "show when each ID last changed",
+			},
+			&cli.BoolFlag{
+				Name:    "long",
+				Aliases: []string{"l"},
+				Usage:   "show all of --files, --size and --modified",
+			},
+		},
+		Action: ls,
+	}
+}
+
+// ls prints the tree under root, optionally filtered to one area, category or ID.
+func ls(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	t, err := tree.Load(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return fmt.Errorf("failed to read tree: %w", err)
+	}
+
+	if cmd.Args().Len() > 1 {
+		return fmt.Errorf("expected at most one area, category or ID")
+	}
+	if cmd.Args().Len() == 1 {
+		t, err = t.Filter(cmd.Args().First())
+		if err != nil {
+			return err
+		}
+	}
+
+	long := cmd.Bool("long")
+	columns := lsColumns{
+		files:    long || cmd.Bool("files"),
+		size:     long || cmd.Bool("size"),
+		modified: long || cmd.Bool("modified"),
+	}
+	if columns.any() {
+		if err := t.LoadStats(); err != nil {
+			return fmt.Errorf("failed to read ID folders: %w", err)
+		}
+	}
+
+	switch strings.ToLower(cmd.String("format")) {
+	case "text":
+		return writeLsText(os.Stdout, t, columns)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(t)
+	case "csv":
+		return writeLsCSV(os.Stdout, t, columns)
+	default:
+		return fmt.Errorf("unknown format %q (expected text, json or csv)", cmd.String("format"))
+	}
+}
+
+// writeLsText prints the tree indented by level, with stats columns aligned.
+func writeLsText(w io.Writer, t *tree.Tree, columns lsColumns) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, area := range t.Areas {
+		fmt.Fprintf(tw, "%s\n", area.Label())
+		for _, category := range area.Categories {
+			fmt.Fprintf(tw, "  %s\n", category.Label())
+			for _, id := range category.IDs {
+				fmt.Fprintf(tw, "    %s", id.Label())
+				if columns.any() {
+					for _, col := range statsColumns(id.Stats, columns, true) {
+						fmt.Fprintf(tw, "\t%s", col)
+					}
+				}
+				fmt.Fprintln(tw)
+			}
+		}
+	}
+
+	return tw.Flush()
+}
+
+// writeLsCSV writes one row per area, category and ID, with stats columns for IDs.
+func writeLsCSV(w io.Writer, t *tree.Tree, columns lsColumns) error {
+	header := append([]string{"level", "number", "name", "path"}, statsColumnNames(columns)...)
+	empty := make([]string, len(header)-4)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	for _, area := range t.Areas {
+		if err := cw.Write(append([]string{"area", area.Number, area.Name, area.Path}, empty...)); err != nil {
+			return err
+		}
+		for _, category := range area.Categories {
+			if err := cw.Write(append([]string{"category", category.Number, category.Name, category.Path}, empty...)); err != nil {
+				return err
+			}
+			for _, id := range category.IDs {
+				row := append([]string{"id", id.Number, id.Name, id.Path}, statsColumns(id.Stats, columns, false)...)
+				if err := cw.Write(row); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// statsColumnNames returns the CSV header names of the enabled stats columns.
+func statsColumnNames(columns lsColumns) []string {
+	var names []string
+	if columns.files {
+		names = append(names, "files")
+	}
+	if columns.size {
+		names = append(names, "size")
+	}
+	if columns.modified {
+		names = append(names, "modified")
+	}
+	return names
+}
+
+// statsColumns formats the enabled stats columns, for humans or for machines.
+func statsColumns(stats *tree.Stats, columns lsColumns, human bool) []string {
+	if stats == nil {
+		stats = &tree.Stats{}
+	}
+
+	var cols []string
+	if columns.files {
+		switch {
+		case human && stats.Files == 1:
+			cols = append(cols, "1 file")
+		case human:
+			cols = append(cols, fmt.Sprintf("%d files", stats.Files))
+		default:
+			cols = append(cols, strconv.Itoa(stats.Files))
+		}
+	}
+	if columns.size {
+		if human {
+			cols = append(cols, humanSize(stats.Size))
+		} else {
+			cols = append(cols, strconv.FormatInt(stats.Size, 10))
+		}
+	}
+	if columns.modified {
+		switch {
+		case stats.Modified.IsZero():
+			cols = append(cols, "-")
+		case human:
+			cols = append(cols, stats.Modified.Local().Format("2006-01-02 15:04"))
+		default:
+			cols = append(cols, stats.Modified.Format(time.RFC3339))
+		}
+	}
+	return cols
+}
+
+// humanSize formats a byte count using binary units, e.g. "1.5 MiB".
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
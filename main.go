@@ -1,21 +1,28 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/gen2brain/beeep"
 	"github.com/mahyarmirrashed/jdd/internal/config"
 	jdd "github.com/mahyarmirrashed/jdd/internal/daemon"
+	"github.com/mahyarmirrashed/jdd/internal/retry"
+	"github.com/mahyarmirrashed/jdd/internal/rules"
+	"github.com/mahyarmirrashed/jdd/internal/suggest"
 	"github.com/sevlyar/go-daemon"
 	log "github.com/sirupsen/logrus"
 	altsrc "github.com/urfave/cli-altsrc/v3"
 	"github.com/urfave/cli-altsrc/v3/yaml"
 	"github.com/urfave/cli/v3"
+	yamlv3 "gopkg.in/yaml.v3"
 )
 
 // Set at build time: go build -ldflags "-X main.version=1.2.3"
@@ -44,6 +51,8 @@ func main() {
 		Version:               version,
 		EnableShellCompletion: true,
 		Suggest:               true,
+		// Lists from the config file arrive one item per line; see yamlList
+		SliceFlagSeparator: "\n",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "root",
@@ -73,7 +82,7 @@ func main() {
 				Name:    "exclude",
 				Usage:   "glob patterns to exclude (repeat or comma-separated)",
 				Value:   []string{},
-				Sources: cli.NewValueSourceChain(yaml.YAML("exclude", configFile), cli.EnvVar("JDD_EXCLUDE")),
+				Sources: cli.NewValueSourceChain(yamlList("exclude", configFile), cli.EnvVar("JDD_EXCLUDE")),
 			},
 			&cli.DurationFlag{
 				Name:    "delay",
@@ -87,36 +96,178 @@ func main() {
 				Value:   false,
 				Sources: cli.NewValueSourceChain(yaml.YAML("notifications", configFile), cli.EnvVar("JDD_NOTIFICATIONS")),
 			},
+			&cli.BoolFlag{
+				Name:    "index",
+				Usage:   "keep the index file in sync with the folder tree",
+				Value:   false,
+				Sources: cli.NewValueSourceChain(yaml.YAML("index", configFile), cli.EnvVar("JDD_INDEX")),
+			},
+			&cli.StringFlag{
+				Name:    "index-file",
+				Usage:   "index file maintained by --index, relative to root",
+				Value:   config.DefaultIndexFile,
+				Sources: cli.NewValueSourceChain(yaml.YAML("index_file", configFile), cli.EnvVar("JDD_INDEX_FILE")),
+			},
+			&cli.StringSliceFlag{
+				Name:    "reserved",
+				Usage:   "ID ranges within each category never allocated automatically, e.g. 00-09 (repeat or comma-separated)",
+				Value:   []string{},
+				Sources: cli.NewValueSourceChain(yamlList("reserved", configFile), cli.EnvVar("JDD_RESERVED")),
+			},
+			&cli.BoolFlag{
+				Name:    "fix-folders",
+				Usage:   "move ID and category folders created or moved into the wrong place to where they belong",
+				Value:   false,
+				Sources: cli.NewValueSourceChain(yaml.YAML("fix_folders", configFile), cli.EnvVar("JDD_FIX_FOLDERS")),
+			},
+			&cli.StringSliceFlag{
+				Name:    "pattern",
+				Usage:   "filename patterns to find IDs with, tried in order: a preset name or an expression with category, id and optional subid named groups (repeat, or one per line in JDD_PATTERNS; default: prefix)",
+				Value:   []string{},
+				Sources: cli.NewValueSourceChain(yamlList("patterns", configFile), cli.EnvVar("JDD_PATTERNS")),
+			},
+			&cli.StringSliceFlag{
+				Name:    "system",
+				Usage:   "root directory of a JD system as CODE=DIR, e.g. P01=~/Personal (repeat or comma-separated)",
+				Value:   []string{},
+				Sources: cli.NewValueSourceChain(yamlList("systems", configFile), cli.EnvVar("JDD_SYSTEMS")),
+			},
+			&cli.StringFlag{
+				Name:    "scheme",
+				Usage:   "classification scheme used by the daemon: johnny-decimal, dewey or para",
+				Value:   "johnny-decimal",
+				Sources: cli.NewValueSourceChain(yaml.YAML("scheme", configFile), cli.EnvVar("JDD_SCHEME")),
+			},
+			&cli.IntFlag{
+				Name:    "dewey-depth",
+				Usage:   "folder levels for the dewey scheme: 3 for 500/510/512, more for each decimal digit",
+				Value:   3,
+				Sources: cli.NewValueSourceChain(yaml.YAML("dewey_depth", configFile), cli.EnvVar("JDD_DEWEY_DEPTH")),
+			},
+			&cli.IntFlag{
+				Name:    "sub-id-depth",
+				Usage:   "most nested sub-ID folder levels, e.g. 1 files 15.23+JEM+2024 in 15.23+JEM (0: no limit)",
+				Value:   0,
+				Sources: cli.NewValueSourceChain(yaml.YAML("sub_id_depth", configFile), cli.EnvVar("JDD_SUB_ID_DEPTH")),
+			},
+			&cli.BoolFlag{
+				Name:    "flatten-sub-ids",
+				Usage:   "file every sub-ID level in one folder, e.g. 15.23+JEM+2024",
+				Value:   false,
+				Sources: cli.NewValueSourceChain(yaml.YAML("flatten_sub_ids", configFile), cli.EnvVar("JDD_FLATTEN_SUB_IDS")),
+			},
+			&cli.StringSliceFlag{
+				Name:    "date-bucket",
+				Usage:   "file into YYYY or YYYY-MM subfolders of an ID or category, as KEY=year or KEY=month, e.g. 15.11=month (repeat or comma-separated)",
+				Value:   []string{},
+				Sources: cli.NewValueSourceChain(yamlList("date_buckets", configFile), cli.EnvVar("JDD_DATE_BUCKETS")),
+			},
+			&cli.StringSliceFlag{
+				Name:    "date-source",
+				Usage:   "where date buckets take a file's date from, tried in order: filename, metadata, mtime",
+				Value:   []string{"filename", "metadata", "mtime"},
+				Sources: cli.NewValueSourceChain(yamlList("date_sources", configFile), cli.EnvVar("JDD_DATE_SOURCES")),
+			},
+			&cli.StringSliceFlag{
+				Name:    "inbox",
+				Usage:   "extra directory whose files are filed into root, e.g. ~/Downloads (repeat or comma-separated)",
+				Value:   []string{},
+				Sources: cli.NewValueSourceChain(yamlList("inboxes", configFile), cli.EnvVar("JDD_INBOXES")),
+			},
+			&cli.BoolFlag{
+				Name:    "suggest",
+				Usage:   "file unprefixed files in root or an inbox under the ID learned from the existing tree, when confident enough",
+				Value:   false,
+				Sources: cli.NewValueSourceChain(yaml.YAML("suggest", configFile), cli.EnvVar("JDD_SUGGEST")),
+			},
+			&cli.FloatFlag{
+				Name:    "suggest-threshold",
+				Usage:   "confidence, from 0 to 1, needed to file by suggestion; less certain files are queued for review",
+				Value:   suggest.DefaultThreshold,
+				Sources: cli.NewValueSourceChain(yaml.YAML("suggest_threshold", configFile), cli.EnvVar("JDD_SUGGEST_THRESHOLD")),
+			},
+			&cli.StringFlag{
+				Name:    "quarantine",
+				Usage:   "holding folder for files that have no ID or cannot be filed, such as \"00.01 Unsorted\"; an ID is placed in the tree, anything else is relative to root",
+				Sources: cli.NewValueSourceChain(yaml.YAML("quarantine", configFile), cli.EnvVar("JDD_QUARANTINE")),
+			},
+			&cli.IntFlag{
+				Name:    "retry-attempts",
+				Usage:   "most attempts to move a file that is briefly locked, busy or mid-rename before quarantining it",
+				Value:   retry.DefaultAttempts,
+				Sources: cli.NewValueSourceChain(yaml.YAML("retry_attempts", configFile), cli.EnvVar("JDD_RETRY_ATTEMPTS")),
+			},
+			&cli.DurationFlag{
+				Name:    "retry-backoff",
+				Usage:   "delay before the first retry, doubled for each next one",
+				Value:   retry.DefaultBackoff,
+				Sources: cli.NewValueSourceChain(yaml.YAML("retry_backoff", configFile), cli.EnvVar("JDD_RETRY_BACKOFF")),
+			},
+			&cli.BoolFlag{
+				Name:    "full-rescan",
+				Usage:   "process every file at start-up, not only those changed since the last run",
+				Value:   false,
+				Sources: cli.NewValueSourceChain(yaml.YAML("full_rescan", configFile), cli.EnvVar("JDD_FULL_RESCAN")),
+			},
+			&cli.IntFlag{
+				Name:    "scan-workers",
+				Usage:   "directories scanned at once at start-up",
+				Value:   jdd.DefaultScanWorkers,
+				Sources: cli.NewValueSourceChain(yaml.YAML("scan_workers", configFile), cli.EnvVar("JDD_SCAN_WORKERS")),
+			},
+			&cli.IntFlag{
+				Name:    "brake-moves",
+				Usage:   "pause moving files, until confirmed with \"jdd resume\", after more than this many moves in a minute; 0 disables",
+				Value:   jdd.DefaultBrakeMoves,
+				Sources: cli.NewValueSourceChain(yaml.YAML("brake_moves", configFile), cli.EnvVar("JDD_BRAKE_MOVES")),
+			},
+			&cli.IntFlag{
+				Name:    "brake-burst",
+				Usage:   "pause moving files, until confirmed with \"jdd resume\", after more than this many new files in one directory in a minute; 0 disables",
+				Value:   jdd.DefaultBrakeBurst,
+				Sources: cli.NewValueSourceChain(yaml.YAML("brake_burst", configFile), cli.EnvVar("JDD_BRAKE_BURST")),
+			},
+			&cli.BoolFlag{
+				Name:    "force",
+				Usage:   "watch root even if it looks dangerous, e.g. the home directory or a git worktree, and skip confirming a large first scan",
+				Sources: cli.NewValueSourceChain(yaml.YAML("force", configFile), cli.EnvVar("JDD_FORCE")),
+			},
+			&cli.IntFlag{
+				Name:    "confirm-threshold",
+				Usage:   "ask for confirmation when the first scan of root would move more than this many files; 0 disables",
+				Value:   jdd.DefaultConfirmThreshold,
+				Sources: cli.NewValueSourceChain(yaml.YAML("confirm_threshold", configFile), cli.EnvVar("JDD_CONFIRM_THRESHOLD")),
+			},
+			&cli.StringFlag{
+				Name:    "primary-system",
+				Usage:   "system that IDs without a system prefix belong to (default: root itself)",
+				Sources: cli.NewValueSourceChain(yaml.YAML("primary_system", configFile), cli.EnvVar("JDD_PRIMARY_SYSTEM")),
+			},
+		},
+		Commands: []*cli.Command{
+			indexCommand(),
+			findCommand(),
+			lsCommand(),
+			newCommand(),
+			renumberCommand(),
+			checkCommand(),
+			suggestCommand(),
+			explainCommand(),
+			quarantineCommand(),
+			statusCommand(),
+			resumeCommand(),
+			patternCommand(),
+			shellInitCommand(),
+			completeCommand(),
 		},
 		Action: func(ctx context.Context, cmd *cli.Command) error {
-			cfg := &config.Config{
-				Root:          cmd.String("root"),
-				LogLevel:      strings.ToLower(cmd.String("log-level")),
-				Daemonize:     cmd.Bool("daemonize"),
-				DryRun:        cmd.Bool("dry-run"),
-				Delay:         cmd.Duration("delay"),
-				Notifications: cmd.Bool("notifications"),
-			}
-
-			excludes := cmd.StringSlice("exclude")
-			var mergedExclude []string
-			for _, e := range excludes {
-				mergedExclude = append(mergedExclude, strings.Split(e, ",")...)
+			// Other commands skip malformed pairs; the daemon would misroute files
+			if err := checkPairs(cmd); err != nil {
+				return err
 			}
-			cfg.Exclude = mergedExclude
-
-			// Set log level
-			switch cfg.LogLevel {
-			case "debug":
-				log.SetLevel(log.DebugLevel)
-			case "info":
-				log.SetLevel(log.InfoLevel)
-			case "warn":
-				log.SetLevel(log.WarnLevel)
-			case "error":
-				log.SetLevel(log.ErrorLevel)
-			default:
-				log.SetLevel(log.InfoLevel)
+			cfg := newConfig(cmd)
+			if err := loadRules(cfg); err != nil {
+				return err
 			}
 
 			// Log project version at startup
@@ -125,6 +276,18 @@ func main() {
 			// Display configuration
 			log.Debugf("Config set as: %+v", cfg)
 
+			// Check root before daemonizing, while a terminal is still attached;
+			// the daemonized child was checked by its parent
+			if !daemon.WasReborn() {
+				if err := jdd.Preflight(cfg); err != nil {
+					var confirm *jdd.ConfirmError
+					if !errors.As(err, &confirm) || !confirmed(confirm) {
+						log.Fatal(err)
+					}
+				}
+			}
+			cfg.Preflighted = true
+
 			// Only daemonize if config says so
 			if cfg.Daemonize {
 				daemonCtx := &daemon.Context{
@@ -161,3 +324,160 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// confirmed asks on the terminal whether to go ahead with a first scan that
+// moves many files. Without a terminal to ask on, the answer is no.
+func confirmed(confirm *jdd.ConfirmError) bool {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+
+	fmt.Printf("The first scan of %s would move about %d files. Continue? [y/N] ", confirm.Root, confirm.Moves)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// newConfig builds the configuration from the command's flags and applies the log level.
+func newConfig(cmd *cli.Command) *config.Config {
+	cfg := &config.Config{
+		Root:             cmd.String("root"),
+		LogLevel:         strings.ToLower(cmd.String("log-level")),
+		Daemonize:        cmd.Bool("daemonize"),
+		DryRun:           cmd.Bool("dry-run"),
+		Delay:            cmd.Duration("delay"),
+		Notifications:    cmd.Bool("notifications"),
+		Index:            cmd.Bool("index"),
+		IndexFile:        cmd.String("index-file"),
+		FixFolders:       cmd.Bool("fix-folders"),
+		PrimarySystem:    cmd.String("primary-system"),
+		Scheme:           cmd.String("scheme"),
+		DeweyDepth:       int(cmd.Int("dewey-depth")),
+		SubIDDepth:       int(cmd.Int("sub-id-depth")),
+		FlattenSubIDs:    cmd.Bool("flatten-sub-ids"),
+		Suggest:          cmd.Bool("suggest"),
+		SuggestThreshold: cmd.Float("suggest-threshold"),
+		Quarantine:       cmd.String("quarantine"),
+		RetryAttempts:    cmd.Int("retry-attempts"),
+		RetryBackoff:     cmd.Duration("retry-backoff"),
+		FullRescan:       cmd.Bool("full-rescan"),
+		ScanWorkers:      cmd.Int("scan-workers"),
+		BrakeMoves:       cmd.Int("brake-moves"),
+		BrakeBurst:       cmd.Int("brake-burst"),
+		Force:            cmd.Bool("force"),
+		ConfirmThreshold: cmd.Int("confirm-threshold"),
+	}
+
+	cfg.Exclude = splitList(cmd.StringSlice("exclude"))
+	cfg.Reserved = splitList(cmd.StringSlice("reserved"))
+	cfg.Patterns = cmd.StringSlice("pattern")
+	cfg.Systems = keepPairs("system", cmd.StringSlice("system"))
+	cfg.DateBuckets = keepPairs("date bucket", cmd.StringSlice("date-bucket"))
+	cfg.DateSources = splitList(cmd.StringSlice("date-source"))
+	cfg.Inboxes = splitList(cmd.StringSlice("inbox"))
+
+	// Set log level
+	switch cfg.LogLevel {
+	case "debug":
+		log.SetLevel(log.DebugLevel)
+	case "info":
+		log.SetLevel(log.InfoLevel)
+	case "warn":
+		log.SetLevel(log.WarnLevel)
+	case "error":
+		log.SetLevel(log.ErrorLevel)
+	default:
+		log.SetLevel(log.InfoLevel)
+	}
+
+	return cfg
+}
+
+// loadRules adds the rules in the config file to cfg, for the commands that
+// classify files. Rules are structured, so they only come from the config file.
+func loadRules(cfg *config.Config) error {
+	var err error
+	cfg.Rules, err = rules.Load(config.DefaultConfigFilename)
+	return err
+}
+
+// splitList flattens repeated and comma-separated flag values into one list.
+func splitList(values []string) []string {
+	var merged []string
+	for _, v := range values {
+		merged = append(merged, strings.Split(v, ",")...)
+	}
+	return merged
+}
+
+// splitPairs parses repeated and comma-separated KEY=VALUE flag values into a
+// map. Malformed entries are left out, and the first is returned as an error.
+func splitPairs(what string, values []string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	var err error
+	for _, entry := range splitList(values) {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("invalid %s %q, expected KEY=VALUE", what, entry)
+			}
+			continue
+		}
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return pairs, err
+}
+
+// keepPairs is splitPairs for commands that can carry on without the
+// malformed entries, which are only warned about.
+func keepPairs(what string, values []string) map[string]string {
+	pairs, err := splitPairs(what, values)
+	if err != nil {
+		log.Warnf("Ignoring %s", err)
+	}
+	return pairs
+}
+
+// checkPairs returns an error for the first malformed KEY=VALUE flag value.
+func checkPairs(cmd *cli.Command) error {
+	if _, err := splitPairs("system", cmd.StringSlice("system")); err != nil {
+		return err
+	}
+	_, err := splitPairs("date bucket", cmd.StringSlice("date-bucket"))
+	return err
+}
+
+// yamlList reads a key from the config file like yaml.YAML, except that a
+// list is read as one item per line rather than in Go's "[a b]" form, so
+// that slice flags split it back into its items. A map is read the same way,
+// as one "key=value" line per entry.
+func yamlList(key string, source altsrc.Sourcer) *altsrc.ValueSource {
+	unmarshal := func(data []byte, v any) error {
+		if err := yamlv3.Unmarshal(data, v); err != nil {
+			return err
+		}
+		m, ok := v.(*map[any]any)
+		if !ok {
+			return nil
+		}
+		for k, val := range *m {
+			if list, ok := val.([]any); ok {
+				items := make([]string, len(list))
+				for i, item := range list {
+					items[i] = fmt.Sprint(item)
+				}
+				(*m)[k] = strings.Join(items, "\n")
+			}
+			if entries, ok := val.(map[string]any); ok {
+				items := make([]string, 0, len(entries))
+				for key, item := range entries {
+					items = append(items, fmt.Sprintf("%s=%v", key, item))
+				}
+				sort.Strings(items)
+				(*m)[k] = strings.Join(items, "\n")
+			}
+		}
+		return nil
+	}
+	return altsrc.NewValueSource(unmarshal, "yaml", key, source)
+}
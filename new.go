@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+// newCommand allocates the next free ID in a category.
+func newCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "new",
+		Usage:     "create a folder for the next free ID in a category and print its path",
+		ArgsUsage: "CATEGORY NAME...",
+		Action:    newID,
+	}
+}
+
+// newID creates the folder for the next free ID, e.g. "15.24 Japan trip".
+func newID(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	if cmd.Args().Len() < 2 {
+		return fmt.Errorf("expected a category and a name, e.g. jdd new 15 \"Japan trip\"")
+	}
+	category := cmd.Args().First()
+	name := strings.Join(cmd.Args().Tail(), " ")
+
+	reserved, err := jd.ParseRanges(cfg.Reserved)
+	if err != nil {
+		return err
+	}
+
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+
+	if cfg.DryRun {
+		next, err := jd.NextID(root, category, reserved)
+		if err != nil {
+			return err
+		}
+		log.Infof("[dry run] Would create %s %s", next.ID, name)
+		return nil
+	}
+
+	_, path, err := jd.Allocate(root, category, name, reserved)
+	if err != nil {
+		return fmt.Errorf("failed to allocate ID: %w", err)
+	}
+
+	fmt.Println(path)
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mahyarmirrashed/jdd/internal/jd"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	"github.com/urfave/cli/v3"
+)
+
+// patternCommand groups the commands that work with filename patterns.
+func patternCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "pattern",
+		Usage: "work with the filename patterns used to find IDs",
+		Commands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "print the built-in presets and the patterns in use",
+				Action: patternList,
+			},
+			{
+				Name:      "test",
+				Usage:     "show which pattern matches each filename and where the file would be filed",
+				ArgsUsage: "FILENAME...",
+				Action:    patternTest,
+			},
+		},
+	}
+}
+
+// patternList prints the presets, marking those in use, and any custom patterns.
+func patternList(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	patterns, err := jd.ParsePatterns(cfg.Patterns)
+	if err != nil {
+		return err
+	}
+	inUse := make(map[string]bool)
+	for _, p := range patterns {
+		inUse[p.Name] = true
+	}
+
+	for _, name := range jd.PresetNames() {
+		mark := " "
+		if inUse[name] {
+			mark = "*"
+		}
+		fmt.Printf("%s %-18s %s\n", mark, name, jd.Presets[name])
+	}
+	for _, p := range patterns {
+		if _, ok := jd.Presets[p.Name]; !ok {
+			fmt.Printf("* %-18s %s\n", "custom", p.Name)
+		}
+	}
+	return nil
+}
+
+// patternTest reports, for each filename, the pattern that matches it, the ID
+// it carries and the folder it would be filed in. Nothing is created.
+func patternTest(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	if cmd.Args().Len() == 0 {
+		return fmt.Errorf("expected at least one filename")
+	}
+
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+
+	patterns, err := jd.ParsePatterns(cfg.Patterns)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range cmd.Args().Slice() {
+		filename := filepath.Base(arg)
+
+		jdObj, p := jd.Match(patterns, filename)
+		if jdObj == nil {
+			fmt.Printf("%s: no match\n", filename)
+			continue
+		}
+		jdObj.ApplyLayout(cfg.SubIDLayout())
+
+		folder, created, err := plannedFolder(root, jdObj)
+		if err != nil {
+			return err
+		}
+		if created {
+			folder += " (new)"
+		}
+
+		fmt.Printf("%s: %s via %s -> %s\n", filename, jdObj.ID+jdObj.SubID, p.Name, filepath.ToSlash(folder))
+	}
+	return nil
+}
+
+// plannedFolder returns the folder a file carrying jdObj would be filed in,
+// following existing folders as far as they go, and whether any folder along
+// the way would have to be created.
+func plannedFolder(root string, jdObj *jd.JohnnyDecimal) (string, bool, error) {
+	prefixes := jdObj.FolderPrefixes()
+
+	path := root
+	for i, prefix := range prefixes {
+		next, err := jd.FindPrefixedFolder(path, prefix)
+		if errors.Is(err, os.ErrNotExist) {
+			return filepath.Join(append([]string{path}, prefixes[i:]...)...), true, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		path = next
+	}
+	return path, false, nil
+}
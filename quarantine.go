@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mahyarmirrashed/jdd/internal/quarantine"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	"github.com/urfave/cli/v3"
+)
+
+// quarantineCommand lists the files the daemon could not file.
+func quarantineCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "quarantine",
+		Usage: "list the files in the holding folder and why each one could not be filed",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the records as JSON",
+			},
+		},
+		Action: listQuarantine,
+	}
+}
+
+// listQuarantine prints the record of each file still in the holding folder.
+func listQuarantine(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+
+	records, err := quarantine.Records(root)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("json") {
+		if records == nil {
+			records = []quarantine.Record{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+
+	for _, r := range records {
+		fmt.Printf("%s\n  from %s on %s\n  %s\n", filepath.ToSlash(r.To), filepath.ToSlash(r.From), r.Time.Format("2006-01-02 15:04"), r.Reason)
+	}
+	return nil
+}
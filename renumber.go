@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/mahyarmirrashed/jdd/internal/renumber"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v3"
+)
+
+// renumberCommand moves an ID to a new number.
+func renumberCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "renumber",
+		Usage:     "move an ID to a new number, renaming its folder and every file carrying it",
+		ArgsUsage: "FROM TO",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "undo",
+				Usage: "revert the most recent renumbering",
+			},
+		},
+		Action: renumberID,
+	}
+}
+
+// renumberID renumbers an ID, or undoes the last renumbering.
+func renumberID(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("undo") {
+		undone, err := renumber.Undo(root, cfg.DryRun)
+		for _, op := range undone {
+			// Undoing a rename renames back; undoing a mkdir removes the folder
+			if op.Op == renumber.OpRename {
+				logOp(cfg.DryRun, renumber.Op{Op: op.Op, From: op.To, To: op.From})
+			} else {
+				logOp(cfg.DryRun, renumber.Op{Op: "remove", To: op.To})
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("undo failed: %w", err)
+		}
+		return nil
+	}
+
+	if cmd.Args().Len() != 2 {
+		return fmt.Errorf("expected two IDs, e.g. jdd renumber 15.23 16.04")
+	}
+
+	ops, err := renumber.Plan(root, cmd.Args().Get(0), cmd.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	if cfg.DryRun {
+		for _, op := range ops {
+			logOp(true, op)
+		}
+		return nil
+	}
+
+	if err := renumber.Apply(root, ops); err != nil {
+		return fmt.Errorf("renumbering failed, run with --undo to revert the changes made so far: %w", err)
+	}
+	for _, op := range ops {
+		logOp(false, op)
+	}
+	log.Infof("Renumbered %s -> %s (%d change(s)); undo with: jdd renumber --undo", cmd.Args().Get(0), cmd.Args().Get(1), len(ops))
+	return nil
+}
+
+// opVerbs maps operations to how they are logged, done and in dry-run mode.
+var opVerbs = map[string][2]string{
+	renumber.OpMkdir:  {"Created", "[dry run] Would create"},
+	renumber.OpRename: {"Renamed", "[dry run] Would rename"},
+	"remove":          {"Removed", "[dry run] Would remove"},
+}
+
+// logOp logs a single renumbering operation.
+func logOp(dryRun bool, op renumber.Op) {
+	verbs := opVerbs[op.Op]
+	verb := verbs[0]
+	if dryRun {
+		verb = verbs[1]
+	}
+
+	if op.From == "" {
+		log.Infof("%s %s", verb, op.To)
+	} else {
+		log.Infof("%s %s -> %s", verb, op.From, op.To)
+	}
+}
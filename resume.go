@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	jdd "github.com/mahyarmirrashed/jdd/internal/daemon"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	"github.com/urfave/cli/v3"
+)
+
+// resumeCommand lets a daemon paused by its move storm brake continue.
+func resumeCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "resume",
+		Usage: "let a daemon that paused after too many moves continue moving files",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "skip",
+				Usage: "leave the files that arrived while paused where they are",
+			},
+		},
+		Action: resume,
+	}
+}
+
+// resume confirms that the paused daemon on root may continue.
+func resume(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+
+	paused, err := jdd.ReadPaused(root)
+	if err != nil {
+		return err
+	}
+	if paused == nil {
+		return fmt.Errorf("the daemon is not paused")
+	}
+	if err := jdd.Resume(root, cmd.Bool("skip")); err != nil {
+		return err
+	}
+
+	if cmd.Bool("skip") {
+		fmt.Printf("Resumed; %d files that arrived while paused are left where they are\n", paused.Held)
+	} else {
+		fmt.Printf("Resumed; %d files that arrived while paused will be filed\n", paused.Held)
+	}
+	return nil
+}
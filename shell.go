@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/mahyarmirrashed/jdd/internal/tree"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	"github.com/urfave/cli/v3"
+)
+
+// shellInitTemplates define the jcd function and its completion for each shell.
+// Root is already quoted for the target shell.
+var shellInitTemplates = map[string]string{
+	"bash": `# Johnny Decimal Daemon shell integration
+jcd() {
+  local dir
+  dir="$(jdd --root {{.Root}} find "$1")" && cd "$dir"
+}
+_jcd_complete() {
+  local IFS=$'\n'
+  COMPREPLY=($(compgen -W "$(jdd --root {{.Root}} complete 2>/dev/null | cut -f1)" -- "${COMP_WORDS[COMP_CWORD]}"))
+}
+complete -F _jcd_complete jcd
+`,
+	"zsh": `# Johnny Decimal Daemon shell integration
+jcd() {
+  local dir
+  dir="$(jdd --root {{.Root}} find "$1")" && cd "$dir"
+}
+_jcd() {
+  local line
+  local -a entries
+  for line in "${(@f)$(jdd --root {{.Root}} complete 2>/dev/null)}"; do
+    entries+=("${line%%$'\t'*}:${line#*$'\t'}")
+  done
+  _describe -V 'Johnny Decimal folder' entries
+}
+compdef _jcd jcd
+`,
+	"fish": `# Johnny Decimal Daemon shell integration
+function jcd --description 'cd into a Johnny Decimal folder'
+    set -l dir (jdd --root {{.Root}} find $argv[1]); and cd $dir
+end
+function __jcd_complete
+    jdd --root {{.Root}} complete 2>/dev/null
+end
+complete -c jcd -f -k -a '(__jcd_complete)'
+`,
+}
+
+// shellInitCommand prints the shell integration script.
+func shellInitCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "shell-init",
+		Usage:     "print a jcd function with ID completion for bash, zsh or fish",
+		ArgsUsage: "bash|zsh|fish",
+		Description: `Add the output to your shell's startup file, e.g.
+
+   eval "$(jdd --root ~/Documents shell-init bash)"   # ~/.bashrc
+   eval "$(jdd --root ~/Documents shell-init zsh)"    # ~/.zshrc
+   jdd --root ~/Documents shell-init fish | source    # ~/.config/fish/config.fish
+
+Then "jcd 15.23" changes into the folder of ID 15.23. The root is fixed when
+the script is generated.`,
+		Action: shellInit,
+	}
+}
+
+// completeCommand lists areas, categories and IDs for shell completion.
+func completeCommand() *cli.Command {
+	return &cli.Command{
+		Name:   "complete",
+		Usage:  "list areas, categories and IDs with their names, tab-separated",
+		Hidden: true,
+		Action: complete,
+	}
+}
+
+// shellInit writes the shell integration script for the requested shell.
+func shellInit(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	if cmd.Args().Len() != 1 {
+		return fmt.Errorf("expected a shell: bash, zsh or fish")
+	}
+	shell := cmd.Args().First()
+
+	text, ok := shellInitTemplates[shell]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q (expected bash, zsh or fish)", shell)
+	}
+
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+
+	quote := quotePOSIX
+	if shell == "fish" {
+		quote = quoteFish
+	}
+
+	tmpl := template.Must(template.New(shell).Parse(text))
+	return tmpl.Execute(os.Stdout, struct{ Root string }{Root: quote(root)})
+}
+
+// complete prints one "number<TAB>name" line per area, category and ID.
+func complete(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	t, err := tree.Load(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+
+	for _, area := range t.Areas {
+		fmt.Printf("%s\t%s\n", area.Number, area.Name)
+		for _, category := range area.Categories {
+			fmt.Printf("%s\t%s\n", category.Number, category.Name)
+			for _, id := range category.IDs {
+				fmt.Printf("%s\t%s\n", id.Number, id.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// quotePOSIX single-quotes s for bash and zsh.
+func quotePOSIX(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// quoteFish single-quotes s for fish.
+func quoteFish(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	jdd "github.com/mahyarmirrashed/jdd/internal/daemon"
+	"github.com/mahyarmirrashed/jdd/internal/quarantine"
+	"github.com/mahyarmirrashed/jdd/internal/retry"
+	"github.com/mahyarmirrashed/jdd/internal/suggest"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	"github.com/urfave/cli/v3"
+)
+
+// statusResult is the JSON output of the status command.
+type statusResult struct {
+	Paused      *jdd.Paused   `json:"paused,omitempty"`
+	Scan        *jdd.Progress `json:"scan,omitempty"`
+	Retrying    []retry.Entry `json:"retrying"`
+	Review      int           `json:"review"`
+	Quarantined int           `json:"quarantined"`
+}
+
+// statusCommand reports the files the daemon is still working on.
+func statusCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "status",
+		Usage: "show whether the daemon is paused, the progress of its initial scan, and the files waiting to be retried, reviewed or sorted out by hand",
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the status as JSON",
+			},
+		},
+		Action: status,
+	}
+}
+
+// status prints the progress of the last initial scan on root, its retry
+// queue, and how many files wait for review or are in the holding folder.
+func status(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+
+	paused, err := jdd.ReadPaused(root)
+	if err != nil {
+		return err
+	}
+	progress, err := jdd.CurrentProgress(root)
+	if err != nil {
+		return err
+	}
+	retrying, err := retry.Entries(root)
+	if err != nil {
+		return err
+	}
+	reviews, err := suggest.Pending(root)
+	if err != nil {
+		return err
+	}
+	records, err := quarantine.Records(root)
+	if err != nil {
+		return err
+	}
+
+	if cmd.Bool("json") {
+		if retrying == nil {
+			retrying = []retry.Entry{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(statusResult{Paused: paused, Scan: progress, Retrying: retrying, Review: len(reviews), Quarantined: len(records)})
+	}
+
+	if paused != nil {
+		fmt.Printf("Paused since %s: %s; %d files held. Run `jdd resume` to continue.\n", paused.Since.Format("2006-01-02 15:04:05"), paused.Reason, paused.Held)
+	}
+	if progress != nil {
+		state := "running"
+		if progress.Done {
+			state = "finished"
+		}
+		fmt.Printf("Initial scan %s %s: %s\n", state, progress.Updated.Format("2006-01-02 15:04:05"), progress)
+	}
+	fmt.Printf("Retrying: %d\n", len(retrying))
+	for _, e := range retrying {
+		next := "now"
+		if wait := time.Until(e.Next).Round(time.Second); wait > 0 {
+			next = "in " + wait.String()
+		}
+		fmt.Printf("  %s\n    %d failed attempts, next %s: %s\n", filepath.ToSlash(e.Path), e.Attempts, next, e.Error)
+	}
+	fmt.Printf("Waiting for review: %d\n", len(reviews))
+	fmt.Printf("Quarantined: %d\n", len(records))
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mahyarmirrashed/jdd/internal/suggest"
+	"github.com/mahyarmirrashed/jdd/internal/utils"
+	"github.com/urfave/cli/v3"
+)
+
+// suggestCommand suggests IDs for files without one, learned from the tree.
+func suggestCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "suggest",
+		Usage:     "suggest the most likely IDs for files, learned from the files already filed under root",
+		ArgsUsage: "FILE...",
+		Flags: []cli.Flag{
+			&cli.IntFlag{
+				Name:  "top",
+				Usage: "number of suggestions per file",
+				Value: 3,
+			},
+			&cli.BoolFlag{
+				Name:  "queue",
+				Usage: "suggest IDs for the files the daemon queued for review",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the suggestions as JSON",
+			},
+		},
+		Action: suggestIDs,
+	}
+}
+
+// suggestResult is the JSON output of the suggest command, one per file.
+type suggestResult struct {
+	Path        string               `json:"path"`
+	Suggestions []suggest.Suggestion `json:"suggestions"`
+}
+
+// suggestIDs trains a model on root and prints its suggestions for each file.
+func suggestIDs(ctx context.Context, cmd *cli.Command) error {
+	cfg := newConfig(cmd)
+
+	root, err := filepath.Abs(utils.ExpandTilde(cfg.Root))
+	if err != nil {
+		return err
+	}
+
+	paths := cmd.Args().Slice()
+	if cmd.Bool("queue") {
+		reviews, err := suggest.Pending(root)
+		if err != nil {
+			return err
+		}
+		for _, r := range reviews {
+			paths = append(paths, r.Path)
+		}
+	} else if len(paths) == 0 {
+		return fmt.Errorf("expected at least one file")
+	}
+
+	model, err := suggest.Train(root)
+	if err != nil {
+		return err
+	}
+
+	results := make([]suggestResult, 0, len(paths))
+	for _, path := range paths {
+		results = append(results, suggestResult{Path: path, Suggestions: model.Suggest(path, cmd.Int("top"))})
+	}
+
+	if cmd.Bool("json") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+
+	for _, r := range results {
+		fmt.Println(r.Path)
+		if len(r.Suggestions) == 0 {
+			fmt.Println("  no suggestions")
+		}
+		for _, s := range r.Suggestions {
+			fmt.Printf("  %-8s %3.0f%%  %s\n", s.ID, s.Confidence*100, filepath.ToSlash(s.Folder))
+		}
+	}
+	return nil
+}